@@ -0,0 +1,46 @@
+package email
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	DriverSMTP = "smtp"
+	DriverFile = "file"
+)
+
+type Config struct {
+	Driver string     `koanf:"driver"`
+	From   string     `koanf:"from"`
+	SMTP   SMTPConfig `koanf:"smtp"`
+	File   FileConfig `koanf:"file"`
+}
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers a plain text message. Drivers build the full MIME message
+// themselves so callers only deal with Message.
+type Sender interface {
+	Send(message Message) error
+}
+
+func New(config Config) (Sender, error) {
+	switch config.Driver {
+	case DriverSMTP:
+		return NewSMTP(config.From, config.SMTP), nil
+	case DriverFile:
+		return NewFile(config.From, config.File)
+	default:
+		return nil, fmt.Errorf("unknown email driver %q", config.Driver)
+	}
+}
+
+func compose(from string, message Message, date time.Time) []byte {
+	return fmt.Appendf(nil, "From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\nContent-Transfer-Encoding: 8bit\r\n\r\n%s\r\n",
+		from, message.To, message.Subject, date.Format(time.RFC1123Z), message.Body)
+}
@@ -0,0 +1,44 @@
+package email
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type FileConfig struct {
+	Dir string `koanf:"dir"`
+}
+
+// File drops every message as an .eml file into a directory instead of
+// sending it, for development and tests.
+type File struct {
+	from string
+	dir  string
+}
+
+func NewFile(from string, config FileConfig) (File, error) {
+	if config.Dir == "" {
+		return File{}, fmt.Errorf("file email driver needs a directory")
+	}
+	if err := os.MkdirAll(config.Dir, 0o700); err != nil {
+		return File{}, err
+	}
+
+	return File{from: from, dir: config.Dir}, nil
+}
+
+func (f File) Send(message Message) error {
+	now := time.Now()
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+
+	return os.WriteFile(filepath.Join(f.dir, name), compose(f.from, message, now), 0o600)
+}
@@ -0,0 +1,83 @@
+package email
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+type SMTPConfig struct {
+	Host     string        `koanf:"host"`
+	Port     int           `koanf:"port"`
+	Username string        `koanf:"username"`
+	Password string        `koanf:"password"`
+	Timeout  time.Duration `koanf:"timeout"`
+}
+
+type SMTP struct {
+	from   string
+	config SMTPConfig
+}
+
+func NewSMTP(from string, config SMTPConfig) SMTP {
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+
+	return SMTP{from: from, config: config}
+}
+
+// Send delivers message through the configured relay, upgrading to TLS with
+// STARTTLS whenever the server offers it.
+func (s SMTP) Send(message Message) error {
+	addr := net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port))
+
+	conn, err := net.DialTimeout("tcp", addr, s.config.Timeout)
+	if err != nil {
+		return fmt.Errorf("smtp dial: %w", err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(s.config.Timeout))
+
+	client, err := smtp.NewClient(conn, s.config.Host)
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("smtp handshake: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if tErr := client.StartTLS(&tls.Config{ServerName: s.config.Host}); tErr != nil {
+			return fmt.Errorf("smtp starttls: %w", tErr)
+		}
+	}
+
+	if s.config.Username != "" {
+		auth := smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+		if aErr := client.Auth(auth); aErr != nil {
+			return fmt.Errorf("smtp auth: %w", aErr)
+		}
+	}
+
+	if mErr := client.Mail(s.from); mErr != nil {
+		return fmt.Errorf("smtp mail: %w", mErr)
+	}
+	if rErr := client.Rcpt(message.To); rErr != nil {
+		return fmt.Errorf("smtp rcpt: %w", rErr)
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if _, wErr := w.Write(compose(s.from, message, time.Now())); wErr != nil {
+		return fmt.Errorf("smtp write: %w", wErr)
+	}
+	if cErr := w.Close(); cErr != nil {
+		return fmt.Errorf("smtp data: %w", cErr)
+	}
+
+	return client.Quit()
+}
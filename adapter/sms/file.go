@@ -0,0 +1,43 @@
+package sms
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type FileConfig struct {
+	Dir string `koanf:"dir"`
+}
+
+// File drops every message as a .txt file into a directory instead of
+// sending it, for development and tests.
+type File struct {
+	dir string
+}
+
+func NewFile(config FileConfig) (File, error) {
+	if config.Dir == "" {
+		return File{}, fmt.Errorf("file sms driver needs a directory")
+	}
+	if err := os.MkdirAll(config.Dir, 0o700); err != nil {
+		return File{}, err
+	}
+
+	return File{dir: config.Dir}, nil
+}
+
+func (f File) Send(message Message) error {
+	now := time.Now()
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.txt", now.UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+
+	return os.WriteFile(filepath.Join(f.dir, name), fmt.Appendf(nil, "To: %s\n\n%s\n", message.To, message.Body), 0o600)
+}
@@ -0,0 +1,31 @@
+package sms
+
+import "fmt"
+
+const (
+	DriverFile = "file"
+)
+
+type Config struct {
+	Driver string     `koanf:"driver"`
+	File   FileConfig `koanf:"file"`
+}
+
+type Message struct {
+	To   string
+	Body string
+}
+
+// Sender delivers a text message to a phone number in E.164 form.
+type Sender interface {
+	Send(message Message) error
+}
+
+func New(config Config) (Sender, error) {
+	switch config.Driver {
+	case DriverFile:
+		return NewFile(config.File)
+	default:
+		return nil, fmt.Errorf("unknown sms driver %q", config.Driver)
+	}
+}
@@ -0,0 +1,179 @@
+package command
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+	"log"
+	"os"
+	"os/user"
+
+	authService "github.com/hosseinasadian/chat-application/service/authentication/service"
+)
+
+var adminOperator string
+var adminBanReason string
+var adminReports authService.AdminListReportsRequest
+
+var adminCmd = &cobra.Command{
+	Use:   "admin",
+	Short: "Operator tasks on users, sessions and bans",
+	Long: `These commands do what the admin API does, straight against the stores.
+Every action is recorded in the audit log with --operator as the actor.`,
+}
+
+var adminUserCmd = &cobra.Command{
+	Use:   "user <user id|phone>",
+	Short: "Show an account",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		req := authService.AdminLookupUserRequest{Principal: operator()}
+		if uuid.Validate(args[0]) == nil {
+			req.UserID = args[0]
+		} else {
+			req.Phone = args[0]
+		}
+
+		runAdmin(func(authSvc authService.Service) (any, error) {
+			return authSvc.LookupUser(req)
+		})
+	},
+}
+
+var adminRevokeSessionsCmd = &cobra.Command{
+	Use:   "revoke-sessions <user id>",
+	Short: "Log a user out on every device",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runAdmin(func(authSvc authService.Service) (any, error) {
+			return authSvc.RevokeUserSessions(authService.AdminRevokeSessionsRequest{Principal: operator(), UserID: args[0]})
+		})
+	},
+}
+
+var adminUnlockCmd = &cobra.Command{
+	Use:   "unlock <phone>",
+	Short: "Lift the lockout after too many wrong codes",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runAdmin(func(authSvc authService.Service) (any, error) {
+			return authSvc.UnlockLogin(authService.AdminUnlockLoginRequest{Principal: operator(), Phone: args[0]})
+		})
+	},
+}
+
+var adminBanCmd = &cobra.Command{
+	Use:   "ban <phone>",
+	Short: "Ban a phone number and end the sessions of its account",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runAdmin(func(authSvc authService.Service) (any, error) {
+			return authSvc.BanPhone(authService.AdminBanPhoneRequest{Principal: operator(), Phone: args[0], Reason: adminBanReason})
+		})
+	},
+}
+
+var adminUnbanCmd = &cobra.Command{
+	Use:   "unban <phone>",
+	Short: "Lift the ban of a phone number",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runAdmin(func(authSvc authService.Service) (any, error) {
+			return authSvc.UnbanPhone(authService.AdminUnbanPhoneRequest{Principal: operator(), Phone: args[0]})
+		})
+	},
+}
+
+var adminBansCmd = &cobra.Command{
+	Use:   "bans",
+	Short: "List banned phone numbers",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runAdmin(func(authSvc authService.Service) (any, error) {
+			return authSvc.ListPhoneBans(authService.AdminListPhoneBansRequest{Principal: operator()})
+		})
+	},
+}
+
+var adminReportsCmd = &cobra.Command{
+	Use:   "reports",
+	Short: "List moderation reports, newest first",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		adminReports.Principal = operator()
+		runAdmin(func(authSvc authService.Service) (any, error) {
+			return authSvc.ListReports(adminReports)
+		})
+	},
+}
+
+var adminRoleCmd = &cobra.Command{
+	Use:   "role <user id> [support|moderator|admin]",
+	Short: "Give a user an admin role, without a role it is taken away",
+	Args:  cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		req := authService.AdminSetRoleRequest{Principal: operator(), UserID: args[0]}
+		if len(args) == 2 {
+			req.Role = args[1]
+		}
+
+		runAdmin(func(authSvc authService.Service) (any, error) {
+			return authSvc.SetAdminRole(req)
+		})
+	},
+}
+
+var adminRolesCmd = &cobra.Command{
+	Use:   "roles",
+	Short: "List users holding an admin role",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runAdmin(func(authSvc authService.Service) (any, error) {
+			return authSvc.ListAdminRoles(authService.AdminListRolesRequest{Principal: operator()})
+		})
+	},
+}
+
+// runAdmin builds the service, runs action and prints its result as JSON.
+func runAdmin(action func(authSvc authService.Service) (any, error)) {
+	cfg := loadConfig()
+	authSvc, rdAdapter := newAuthService(cfg)
+	defer rdAdapter.Close()
+
+	result, err := action(authSvc)
+	if err != nil {
+		log.Fatalf("Admin command failed: %v", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(result)
+}
+
+func operator() authService.Principal {
+	if adminOperator == "" {
+		log.Fatal("--operator is required")
+	}
+
+	return authService.OperatorPrincipal(adminOperator)
+}
+
+func defaultOperator() string {
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+
+	return os.Getenv("USER")
+}
+
+func init() {
+	adminCmd.PersistentFlags().StringVar(&adminOperator, "operator", defaultOperator(), "who runs the command, recorded in the audit log")
+
+	adminBanCmd.Flags().StringVar(&adminBanReason, "reason", "", "why the number is banned")
+	adminReportsCmd.Flags().StringVar(&adminReports.Before, "before", "", "only reports older than this report id")
+	adminReportsCmd.Flags().IntVar(&adminReports.Limit, "limit", 50, "maximum number of reports")
+
+	adminCmd.AddCommand(adminUserCmd, adminRevokeSessionsCmd, adminUnlockCmd, adminBanCmd, adminUnbanCmd,
+		adminBansCmd, adminReportsCmd, adminRoleCmd, adminRolesCmd)
+	RootCommand.AddCommand(adminCmd)
+}
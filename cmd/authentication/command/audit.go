@@ -0,0 +1,81 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/cobra"
+	"log"
+	"os"
+	"time"
+
+	authService "github.com/hosseinasadian/chat-application/service/authentication/service"
+)
+
+var auditQuery authService.QueryAuditLogRequest
+var auditSince, auditUntil string
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Search the authentication audit log",
+	Long: `This command prints audit log entries of every user, newest first, one
+JSON object per line. Filters are combined, times are RFC 3339. When more
+entries may match, the last line tells the --before value of the next page.
+The query itself is recorded in the audit log with --operator as the actor.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		audit()
+	},
+}
+
+func audit() {
+	var err error
+	if auditQuery.Since, err = parseAuditTime(auditSince); err != nil {
+		log.Fatalf("Invalid --since: %v", err)
+	}
+	if auditQuery.Until, err = parseAuditTime(auditUntil); err != nil {
+		log.Fatalf("Invalid --until: %v", err)
+	}
+
+	auditQuery.Principal = operator()
+
+	cfg := loadConfig()
+	authSvc, rdAdapter := newAuthService(cfg)
+	defer rdAdapter.Close()
+
+	result, err := authSvc.QueryAuditLog(auditQuery)
+	if err != nil {
+		log.Fatalf("Audit query failed: %v", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	for _, event := range result.Events {
+		_ = encoder.Encode(event)
+	}
+	if result.Next != "" {
+		fmt.Printf("more entries, continue with --before %s\n", result.Next)
+	}
+}
+
+func parseAuditTime(raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(time.RFC3339, raw)
+}
+
+func init() {
+	flags := auditCmd.Flags()
+	flags.StringVar(&adminOperator, "operator", defaultOperator(), "who runs the query, recorded in the audit log")
+	flags.StringVar(&auditQuery.UserID, "user", "", "only events of this user id")
+	flags.StringVar(&auditQuery.ActorID, "actor", "", "only actions of this operator, such as operator:alice or an admin user id")
+	flags.StringVar(&auditQuery.Phone, "phone", "", "only events of this phone number")
+	flags.StringVar(&auditQuery.Type, "type", "", "only events of this type, such as otp_failed")
+	flags.StringVar(&auditQuery.IP, "ip", "", "only events from this IP address")
+	flags.StringVar(&auditQuery.DeviceID, "device", "", "only events of this device id")
+	flags.StringVar(&auditSince, "since", "", "only events at or after this time")
+	flags.StringVar(&auditUntil, "until", "", "only events at or before this time")
+	flags.StringVar(&auditQuery.Before, "before", "", "only events older than this entry id")
+	flags.IntVar(&auditQuery.Limit, "limit", 50, "maximum number of events")
+
+	RootCommand.AddCommand(auditCmd)
+}
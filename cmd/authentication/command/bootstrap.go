@@ -0,0 +1,68 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	emailAdapter "github.com/hosseinasadian/chat-application/adapter/email"
+	redisAdapter "github.com/hosseinasadian/chat-application/adapter/redis"
+	smsAdapter "github.com/hosseinasadian/chat-application/adapter/sms"
+	"github.com/hosseinasadian/chat-application/pkg/configloader"
+	"github.com/hosseinasadian/chat-application/service/authentication"
+	authRepository "github.com/hosseinasadian/chat-application/service/authentication/repository"
+	authService "github.com/hosseinasadian/chat-application/service/authentication/service"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+func loadConfig() *authentication.Config {
+	var cfg *authentication.Config
+	workingDir, err := os.Getwd()
+	if err != nil {
+		fmt.Printf("Error getting current working directory: %v", err)
+	}
+
+	yamlPath := os.Getenv("CONFIG_PATH")
+	if yamlPath == "" {
+		yamlPath = filepath.Join(workingDir, "deploy", "authentication", "development", "config.yaml")
+	}
+
+	options := configloader.Option{
+		Prefix:       "AUTHENTICATION_",
+		Delimiter:    ".",
+		Separator:    "__",
+		YamlFilePath: yamlPath,
+		CallbackEnv:  nil,
+	}
+
+	if err := configloader.Load(options, &cfg); err != nil {
+		log.Fatalf("Failed to load food config: %v", err)
+	}
+
+	return cfg
+}
+
+// newAuthService connects to the backing stores and builds the service the
+// way every command needs it.
+func newAuthService(cfg *authentication.Config) (authService.Service, *redisAdapter.Adapter) {
+	rdAdapter, rdErr := redisAdapter.New(context.Background(), cfg.Redis)
+	if rdErr != nil {
+		log.Fatal(rdErr)
+	}
+
+	emailSender, emErr := emailAdapter.New(cfg.Email)
+	if emErr != nil {
+		log.Fatal(emErr)
+	}
+
+	smsSender, smErr := smsAdapter.New(cfg.SMS)
+	if smErr != nil {
+		log.Fatal(smErr)
+	}
+
+	authCache := authRepository.New(*rdAdapter)
+	passkeyRepo := authRepository.NewPasskey(*rdAdapter)
+	userRepo := authRepository.NewUser(*rdAdapter)
+
+	return authService.New(cfg.AuthService, authCache, passkeyRepo, userRepo, emailSender, smsSender), rdAdapter
+}
@@ -0,0 +1,61 @@
+package command
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"log"
+)
+
+var migratePhonesCmd = &cobra.Command{
+	Use:   "migrate-phones",
+	Short: "Move data stored under raw phone numbers to E.164 keys",
+	Long: `This command rewrites accounts, passkeys, bots and other long lived data
+that was stored under phone numbers as the user typed them, such as
+09121234567, to the normalized E.164 form, such as +989121234567.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		migratePhones()
+	},
+}
+
+var migrateUserIDsCmd = &cobra.Command{
+	Use:   "migrate-user-ids",
+	Short: "Move data stored under phone numbers to user ids",
+	Long: `This command assigns every phone number that logged in before user ids
+were introduced a stable user id, then moves passkeys, bots, two-factor
+settings and other long lived data from the phone number to that id.
+Run migrate-phones first.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		migrateUserIDs()
+	},
+}
+
+func migratePhones() {
+	cfg := loadConfig()
+	authSvc, rdAdapter := newAuthService(cfg)
+	defer rdAdapter.Close()
+
+	result, err := authSvc.MigratePhoneKeys()
+	if err != nil {
+		log.Fatalf("Phone migration failed: %v", err)
+	}
+
+	fmt.Printf("renamed %d keys, rewrote %d values, %d conflicts left in place\n", result.Renamed, result.Rewritten, result.Conflicts)
+}
+
+func migrateUserIDs() {
+	cfg := loadConfig()
+	authSvc, rdAdapter := newAuthService(cfg)
+	defer rdAdapter.Close()
+
+	result, err := authSvc.MigrateUserIDs()
+	if err != nil {
+		log.Fatalf("User id migration failed: %v", err)
+	}
+
+	fmt.Printf("renamed %d keys, rewrote %d values, %d conflicts left in place\n", result.Renamed, result.Rewritten, result.Conflicts)
+}
+
+func init() {
+	RootCommand.AddCommand(migratePhonesCmd)
+	RootCommand.AddCommand(migrateUserIDsCmd)
+}
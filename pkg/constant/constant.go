@@ -1,5 +0,0 @@
-package constant
-
-const (
-	PhoneRegex = `^09\d{9}$`
-)
@@ -0,0 +1,49 @@
+package grpcmsg
+
+import (
+	"errors"
+	"github.com/hosseinasadian/chat-application/pkg/richerror"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func Error(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	var richError richerror.RichError
+	switch {
+	case errors.As(err, &richError):
+		return status.Error(MapKindToGRPCCode(richError.Kind()), richError.Message())
+	default:
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+}
+
+func MapKindToGRPCCode(kind richerror.Kind) codes.Code {
+	switch kind {
+	case richerror.KindBadRequest:
+		return codes.InvalidArgument
+	case richerror.KindTooManyRequests:
+		return codes.ResourceExhausted
+	case richerror.KindGone:
+		return codes.FailedPrecondition
+	case richerror.KindInvalid:
+		return codes.InvalidArgument
+	case richerror.KindUnauthorized:
+		return codes.Unauthenticated
+	case richerror.KindUnexpected:
+		return codes.Internal
+	case richerror.KindForbidden:
+		return codes.PermissionDenied
+	case richerror.KindNotFound:
+		return codes.NotFound
+	default:
+		return codes.Unknown
+	}
+}
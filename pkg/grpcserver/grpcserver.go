@@ -0,0 +1,82 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+type Config struct {
+	Host               string        `koanf:"host"`
+	Port               int           `koanf:"port"`
+	ShutDownCtxTimeout time.Duration `koanf:"shut_down_ctx_timeout"`
+}
+
+type Handler interface {
+	Register(server *grpc.Server)
+}
+
+type Server struct {
+	config       Config
+	handler      Handler
+	interceptors []grpc.UnaryServerInterceptor
+	grpcServer   *grpc.Server
+}
+
+// New creates a server whose unary calls pass through the shared recovery,
+// logging and error interceptors followed by the given service specific ones.
+func New(config Config, handler Handler, interceptors ...grpc.UnaryServerInterceptor) Server {
+	return Server{
+		config:       config,
+		handler:      handler,
+		interceptors: interceptors,
+	}
+}
+
+func (s *Server) Serve() error {
+	chain := append([]grpc.UnaryServerInterceptor{
+		RecoveryInterceptor,
+		LoggingInterceptor,
+		ErrorInterceptor,
+	}, s.interceptors...)
+
+	s.grpcServer = grpc.NewServer(grpc.ChainUnaryInterceptor(chain...))
+	s.handler.Register(s.grpcServer)
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", s.config.Host, s.config.Port))
+	if err != nil {
+		return err
+	}
+
+	// blocking until shutdown
+	if err := s.grpcServer.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+	return nil
+}
+
+// Stop waits for in-flight calls to finish and forces the remaining ones
+// closed once grpcShutdownCtx is done.
+func (s *Server) Stop(grpcShutdownCtx context.Context) error {
+	if s.grpcServer == nil {
+		return nil
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-grpcShutdownCtx.Done():
+		s.grpcServer.Stop()
+		return grpcShutdownCtx.Err()
+	}
+}
@@ -0,0 +1,40 @@
+package grpcserver
+
+import (
+	"context"
+	"log"
+	"runtime/debug"
+	"time"
+
+	"github.com/hosseinasadian/chat-application/pkg/grpcmsg"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func RecoveryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (res any, err error) {
+	defer func() {
+		if rvr := recover(); rvr != nil {
+			log.Printf("panic in %s: %v\n%s", info.FullMethod, rvr, debug.Stack())
+			err = status.Error(codes.Internal, "internal error")
+		}
+	}()
+
+	return handler(ctx, req)
+}
+
+func LoggingInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	res, err := handler(ctx, req)
+	log.Printf("%s %s in %s", info.FullMethod, status.Code(err), time.Since(start))
+
+	return res, err
+}
+
+// ErrorInterceptor turns rich errors returned by handlers into gRPC status
+// errors, the same way httpmsg maps them to HTTP status codes.
+func ErrorInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	res, err := handler(ctx, req)
+
+	return res, grpcmsg.Error(err)
+}
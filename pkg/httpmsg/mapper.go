@@ -35,6 +35,10 @@ func MapKindToHTTPStatusCode(kind richerror.Kind) int {
 		return http.StatusUnauthorized
 	case richerror.KindUnexpected:
 		return http.StatusInternalServerError
+	case richerror.KindForbidden:
+		return http.StatusForbidden
+	case richerror.KindNotFound:
+		return http.StatusNotFound
 	default:
 		return http.StatusBadRequest
 	}
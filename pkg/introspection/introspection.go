@@ -0,0 +1,169 @@
+// Package introspection is a client for the authentication service's RFC 7662
+// introspection endpoint, for services that do not verify tokens themselves.
+package introspection
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxCacheEntries = 10000
+
+type Config struct {
+	Endpoint     string        `koanf:"endpoint"`
+	ClientID     string        `koanf:"client_id"`
+	ClientSecret string        `koanf:"client_secret"`
+	CacheTTL     time.Duration `koanf:"cache_ttl"`
+	Timeout      time.Duration `koanf:"timeout"`
+}
+
+type Result struct {
+	Active    bool     `json:"active"`
+	TokenType string   `json:"token_type,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	DeviceID  string   `json:"did,omitempty"`
+	Scope     string   `json:"scope,omitempty"`
+	JTI       string   `json:"jti,omitempty"`
+	OwnerID   string   `json:"owner_id,omitempty"`
+	KeyID     string   `json:"key_id,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  []string `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+}
+
+func (r Result) HasScope(scope string) bool {
+	for _, s := range strings.Fields(r.Scope) {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+type cacheEntry struct {
+	result    Result
+	expiresAt time.Time
+}
+
+type Client struct {
+	config     Config
+	httpClient *http.Client
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+func New(config Config) *Client {
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+
+	return &Client{
+		config:     config,
+		httpClient: &http.Client{Timeout: timeout},
+		cache:      make(map[string]cacheEntry),
+	}
+}
+
+// Introspect asks the authentication service about token. Answers are cached
+// for CacheTTL, and an active answer never outlives the token's own expiry.
+// A revoked token may therefore still be reported active for up to CacheTTL.
+func (c *Client) Introspect(ctx context.Context, token, tokenTypeHint string) (Result, error) {
+	key := cacheKey(token, tokenTypeHint)
+	if res, ok := c.cached(key); ok {
+		return res, nil
+	}
+
+	form := url.Values{"token": {token}}
+	if tokenTypeHint != "" {
+		form.Set("token_type_hint", tokenTypeHint)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.config.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(c.config.ClientID, c.config.ClientSecret)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return Result{}, fmt.Errorf("introspection request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Result{}, fmt.Errorf("introspection request failed: %s", resp.Status)
+	}
+
+	var res Result
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return Result{}, fmt.Errorf("introspection response malformed: %w", err)
+	}
+
+	c.store(key, res)
+
+	return res, nil
+}
+
+func (c *Client) cached(key string) (Result, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.cache[key]
+	if !ok {
+		return Result{}, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.cache, key)
+		return Result{}, false
+	}
+
+	return entry.result, true
+}
+
+func (c *Client) store(key string, res Result) {
+	if c.config.CacheTTL <= 0 {
+		return
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(c.config.CacheTTL)
+	if res.Active && res.ExpiresAt != 0 {
+		if tokenExp := time.Unix(res.ExpiresAt, 0); tokenExp.Before(expiresAt) {
+			expiresAt = tokenExp
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.cache) >= maxCacheEntries {
+		for k, entry := range c.cache {
+			if now.After(entry.expiresAt) {
+				delete(c.cache, k)
+			}
+		}
+	}
+	if len(c.cache) >= maxCacheEntries {
+		return
+	}
+
+	c.cache[key] = cacheEntry{result: res, expiresAt: expiresAt}
+}
+
+func cacheKey(token, tokenTypeHint string) string {
+	sum := sha256.Sum256([]byte(tokenTypeHint + ":" + token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,107 @@
+// Package phone parses user supplied phone numbers into E.164, the single form
+// numbers are stored and compared in.
+package phone
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/nyaruka/phonenumbers"
+)
+
+var (
+	ErrInvalid          = errors.New("is not a valid mobile number")
+	ErrRegionNotAllowed = errors.New("is from a country that is not supported")
+)
+
+type Config struct {
+	// DefaultRegion is the ISO 3166 country assumed for numbers written
+	// without a country code, such as 09121234567.
+	DefaultRegion  string   `koanf:"default_region"`
+	AllowedRegions []string `koanf:"allowed_regions"`
+	DeniedRegions  []string `koanf:"denied_regions"`
+}
+
+type Parser struct {
+	defaultRegion string
+	allowed       map[string]bool
+	denied        map[string]bool
+}
+
+func New(config Config) Parser {
+	p := Parser{
+		defaultRegion: strings.ToUpper(config.DefaultRegion),
+		allowed:       regionSet(config.AllowedRegions),
+		denied:        regionSet(config.DeniedRegions),
+	}
+	if p.defaultRegion == "" {
+		p.defaultRegion = "IR"
+	}
+
+	return p
+}
+
+// Normalize returns raw in E.164 form, for example +989121234567. Only mobile
+// numbers are accepted since logins are confirmed by SMS. An empty allow list
+// allows every region that is not denied.
+func (p Parser) Normalize(raw string) (string, error) {
+	number, err := phonenumbers.Parse(NormalizeDigits(raw), p.defaultRegion)
+	if err != nil || !phonenumbers.IsValidNumber(number) {
+		return "", ErrInvalid
+	}
+
+	switch phonenumbers.GetNumberType(number) {
+	case phonenumbers.MOBILE, phonenumbers.FIXED_LINE_OR_MOBILE:
+	default:
+		return "", ErrInvalid
+	}
+
+	region := phonenumbers.GetRegionCodeForNumber(number)
+	if p.denied[region] || (len(p.allowed) > 0 && !p.allowed[region]) {
+		return "", ErrRegionNotAllowed
+	}
+
+	return phonenumbers.Format(number, phonenumbers.E164), nil
+}
+
+// Region returns the ISO 3166 country of a number already in E.164 form,
+// empty when it cannot be told.
+func (p Parser) Region(e164 string) string {
+	number, err := phonenumbers.Parse(e164, p.defaultRegion)
+	if err != nil {
+		return ""
+	}
+
+	return phonenumbers.GetRegionCodeForNumber(number)
+}
+
+// NormalizeDigits converts Persian and Arabic-Indic digits to ASCII and drops
+// the invisible direction marks that come along when a number is copied out
+// of right-to-left text.
+func NormalizeDigits(raw string) string {
+	var b strings.Builder
+	b.Grow(len(raw))
+
+	for _, r := range strings.TrimSpace(raw) {
+		switch {
+		case r >= '\u06f0' && r <= '\u06f9': // Persian
+			b.WriteRune('0' + (r - '\u06f0'))
+		case r >= '\u0660' && r <= '\u0669': // Arabic-Indic
+			b.WriteRune('0' + (r - '\u0660'))
+		case r == '\u200e' || r == '\u200f' || (r >= '\u202a' && r <= '\u202e') || (r >= '\u2066' && r <= '\u2069'):
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+func regionSet(regions []string) map[string]bool {
+	set := make(map[string]bool, len(regions))
+	for _, region := range regions {
+		set[strings.ToUpper(strings.TrimSpace(region))] = true
+	}
+
+	return set
+}
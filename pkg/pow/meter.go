@@ -0,0 +1,68 @@
+package pow
+
+import (
+	"sync"
+	"time"
+)
+
+// Meter counts requests over a sliding window so the difficulty of new
+// puzzles can follow the load. It only sees the requests of its own process.
+type Meter struct {
+	window time.Duration
+
+	mu       sync.Mutex
+	started  time.Time
+	current  int
+	previous int
+}
+
+func NewMeter(window time.Duration) *Meter {
+	return &Meter{window: window, started: time.Now()}
+}
+
+// Observe counts one request.
+func (m *Meter) Observe() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.advance(time.Now())
+	m.current++
+}
+
+// Rate estimates the requests of the last window, weighing the previous
+// window by how much of it is still covered.
+func (m *Meter) Rate() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.advance(now)
+	remaining := 1 - float64(now.Sub(m.started))/float64(m.window)
+	return m.current + int(float64(m.previous)*remaining)
+}
+
+func (m *Meter) advance(now time.Time) {
+	elapsed := now.Sub(m.started)
+	if elapsed < m.window {
+		return
+	}
+
+	if elapsed < 2*m.window {
+		m.previous = m.current
+	} else {
+		m.previous = 0
+	}
+	m.current = 0
+	m.started = now.Add(-(elapsed % m.window))
+}
+
+// ScaleDifficulty adds a bit to base for every doubling of rate over
+// threshold, doubling the expected work each time, and stops at limit.
+func ScaleDifficulty(base, limit, rate, threshold int) int {
+	difficulty := base
+	for load := rate; threshold > 0 && load > threshold && difficulty < limit; load /= 2 {
+		difficulty++
+	}
+
+	return min(difficulty, MaxDifficulty)
+}
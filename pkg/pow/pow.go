@@ -0,0 +1,151 @@
+// Package pow implements hashcash style proofs of work over puzzles signed by
+// the server, so handing out puzzles needs no state. A nonce solves a puzzle
+// when SHA-256 of "<puzzle>:<nonce>" starts with as many zero bits as the
+// puzzle's difficulty.
+package pow
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxDifficulty keeps puzzles solvable in a browser.
+const MaxDifficulty = 32
+
+var (
+	ErrInvalid  = errors.New("puzzle is invalid")
+	ErrExpired  = errors.New("puzzle has expired")
+	ErrUnsolved = errors.New("nonce does not solve the puzzle")
+)
+
+var encoding = base64.RawURLEncoding
+
+// Issuer signs and checks puzzles. Every instance sharing the secret accepts
+// the puzzles of the others.
+type Issuer struct {
+	secret []byte
+}
+
+func NewIssuer(secret string) Issuer {
+	return Issuer{secret: []byte(secret)}
+}
+
+// Issue returns a puzzle of difficulty zero bits valid for ttl. A puzzle is
+// only accepted again with the same binding, such as the phone number it was
+// issued for.
+func (i Issuer) Issue(difficulty int, ttl time.Duration, binding string) (string, error) {
+	difficulty = min(max(difficulty, 1), MaxDifficulty)
+
+	seed := make([]byte, 16)
+	if _, err := rand.Read(seed); err != nil {
+		return "", err
+	}
+
+	body := encoding.EncodeToString(seed) + "." + strconv.Itoa(difficulty) + "." + strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	return body + "." + i.sign(body, binding), nil
+}
+
+// Verify checks that nonce solves puzzle and that puzzle was issued by us
+// for binding and has not expired. It cannot tell whether the solution was
+// used before, callers that care remember the puzzles they accepted.
+func (i Issuer) Verify(puzzle, nonce, binding string) error {
+	body, signature, ok := cutLast(puzzle, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(i.sign(body, binding))) {
+		return ErrInvalid
+	}
+
+	difficulty, expiresAt, err := parse(body)
+	if err != nil {
+		return err
+	}
+	if time.Now().Unix() > expiresAt {
+		return ErrExpired
+	}
+
+	if !Solves(puzzle, nonce, difficulty) {
+		return ErrUnsolved
+	}
+
+	return nil
+}
+
+// Difficulty returns the number of zero bits puzzle asks for.
+func Difficulty(puzzle string) (int, error) {
+	body, _, ok := cutLast(puzzle, ".")
+	if !ok {
+		return 0, ErrInvalid
+	}
+
+	difficulty, _, err := parse(body)
+	return difficulty, err
+}
+
+// Solves reports whether nonce solves puzzle at difficulty.
+func Solves(puzzle, nonce string, difficulty int) bool {
+	sum := sha256.Sum256([]byte(puzzle + ":" + nonce))
+	return leadingZeroBits(sum[:]) >= difficulty
+}
+
+// Solve finds a nonce for puzzle, it is what a client does.
+func Solve(puzzle string) (string, error) {
+	difficulty, err := Difficulty(puzzle)
+	if err != nil {
+		return "", err
+	}
+
+	for n := uint64(0); ; n++ {
+		nonce := strconv.FormatUint(n, 36)
+		if Solves(puzzle, nonce, difficulty) {
+			return nonce, nil
+		}
+	}
+}
+
+func (i Issuer) sign(body, binding string) string {
+	mac := hmac.New(sha256.New, i.secret)
+	mac.Write([]byte(body + "\x00" + binding))
+	return encoding.EncodeToString(mac.Sum(nil))
+}
+
+func parse(body string) (difficulty int, expiresAt int64, err error) {
+	parts := strings.Split(body, ".")
+	if len(parts) != 3 {
+		return 0, 0, ErrInvalid
+	}
+
+	difficulty, dErr := strconv.Atoi(parts[1])
+	expiresAt, eErr := strconv.ParseInt(parts[2], 10, 64)
+	if dErr != nil || eErr != nil || difficulty < 1 || difficulty > MaxDifficulty {
+		return 0, 0, ErrInvalid
+	}
+
+	return difficulty, expiresAt, nil
+}
+
+func cutLast(s, sep string) (string, string, bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return "", "", false
+	}
+
+	return s[:i], s[i+len(sep):], true
+}
+
+func leadingZeroBits(sum []byte) int {
+	count := 0
+	for _, b := range sum {
+		if b != 0 {
+			return count + bits.LeadingZeros8(b)
+		}
+		count += 8
+	}
+
+	return count
+}
@@ -11,6 +11,8 @@ const (
 	KindInvalid
 	KindUnauthorized
 	KindUnexpected
+	KindForbidden
+	KindNotFound
 )
 
 type Operation string
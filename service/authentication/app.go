@@ -9,20 +9,28 @@ import (
 	"sync"
 	"syscall"
 
+	"github.com/hosseinasadian/chat-application/pkg/grpcserver"
 	"github.com/hosseinasadian/chat-application/pkg/httpserver"
 )
 
 type Application struct {
-	Logger     *slog.Logger
-	Config     Config
-	HTTPServer httpserver.Server
+	Logger          *slog.Logger
+	Config          Config
+	HTTPServer      httpserver.Server
+	AdminHTTPServer httpserver.Server
+	GRPCServer      grpcserver.Server
+	// Jobs run in the background until the shutdown signal
+	Jobs []func(ctx context.Context)
 }
 
-func Setup(logger *slog.Logger, config Config, server httpserver.Server) Application {
+func Setup(logger *slog.Logger, config Config, server httpserver.Server, adminServer httpserver.Server, grpcServer grpcserver.Server, jobs ...func(ctx context.Context)) Application {
 	return Application{
-		Logger:     logger,
-		Config:     config,
-		HTTPServer: server,
+		Logger:          logger,
+		Config:          config,
+		HTTPServer:      server,
+		AdminHTTPServer: adminServer,
+		GRPCServer:      grpcServer,
+		Jobs:            jobs,
 	}
 }
 
@@ -33,6 +41,7 @@ func (app *Application) Start() {
 	defer stop()
 
 	startServers(app, &wg)
+	startJobs(ctx, app, &wg)
 	<-ctx.Done()
 	app.Logger.Info("✅ Shutdown signal received...")
 
@@ -56,10 +65,40 @@ func startServers(app *Application, wg *sync.WaitGroup) {
 		defer wg.Done()
 		app.Logger.Info(fmt.Sprintf("✅ HTTP server started on %d", app.Config.HTTPServer.Port))
 		if err := app.HTTPServer.Serve(); err != nil {
-			app.Logger.Error(fmt.Sprintf("❌ error in HTTP server on %d", app.Config.HTTPServer.Port), err)
+			app.Logger.Error(fmt.Sprintf("❌ error in HTTP server on %d: %v", app.Config.HTTPServer.Port, err))
 		}
 		app.Logger.Info(fmt.Sprintf("✅ HTTP server stopped %d", app.Config.HTTPServer.Port))
 	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		app.Logger.Info(fmt.Sprintf("✅ admin HTTP server started on %d", app.Config.AdminHTTPServer.Port))
+		if err := app.AdminHTTPServer.Serve(); err != nil {
+			app.Logger.Error(fmt.Sprintf("❌ error in admin HTTP server on %d: %v", app.Config.AdminHTTPServer.Port, err))
+		}
+		app.Logger.Info(fmt.Sprintf("✅ admin HTTP server stopped %d", app.Config.AdminHTTPServer.Port))
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		app.Logger.Info(fmt.Sprintf("✅ gRPC server started on %d", app.Config.GRPCServer.Port))
+		if err := app.GRPCServer.Serve(); err != nil {
+			app.Logger.Error(fmt.Sprintf("❌ error in gRPC server on %d: %v", app.Config.GRPCServer.Port, err))
+		}
+		app.Logger.Info(fmt.Sprintf("✅ gRPC server stopped %d", app.Config.GRPCServer.Port))
+	}()
+}
+
+func startJobs(ctx context.Context, app *Application, wg *sync.WaitGroup) {
+	for _, job := range app.Jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			job(ctx)
+		}()
+	}
 }
 
 func (app *Application) shutdownServers(ctx context.Context) bool {
@@ -68,8 +107,10 @@ func (app *Application) shutdownServers(ctx context.Context) bool {
 
 	go func() {
 		var shutdownWg sync.WaitGroup
-		shutdownWg.Add(1)
+		shutdownWg.Add(3)
 		go app.shutdownHTTPServer(&shutdownWg)
+		go app.shutdownAdminHTTPServer(&shutdownWg)
+		go app.shutdownGRPCServer(&shutdownWg)
 
 		shutdownWg.Wait()
 		close(shutdownDone)
@@ -97,6 +138,32 @@ func (app *Application) shutdownHTTPServer(wg *sync.WaitGroup) {
 	app.Logger.Info("✅ HTTP server shut down successfully.")
 }
 
+func (app *Application) shutdownAdminHTTPServer(wg *sync.WaitGroup) {
+	app.Logger.Info(fmt.Sprintf("✅ Starting graceful shutdown for admin HTTP server on port %d", app.Config.AdminHTTPServer.Port))
+
+	defer wg.Done()
+	httpShutdownCtx, httpCancel := context.WithTimeout(context.Background(), app.Config.AdminHTTPServer.ShutDownCtxTimeout)
+	defer httpCancel()
+	if err := app.AdminHTTPServer.Stop(httpShutdownCtx); err != nil {
+		app.Logger.Error(fmt.Sprintf("❌ admin HTTP server graceful shutdown failed: %v", err))
+	}
+
+	app.Logger.Info("✅ admin HTTP server shut down successfully.")
+}
+
+func (app *Application) shutdownGRPCServer(wg *sync.WaitGroup) {
+	app.Logger.Info(fmt.Sprintf("✅ Starting graceful shutdown for gRPC server on port %d", app.Config.GRPCServer.Port))
+
+	defer wg.Done()
+	grpcShutdownCtx, grpcCancel := context.WithTimeout(context.Background(), app.Config.GRPCServer.ShutDownCtxTimeout)
+	defer grpcCancel()
+	if err := app.GRPCServer.Stop(grpcShutdownCtx); err != nil {
+		app.Logger.Error(fmt.Sprintf("❌ gRPC server graceful shutdown failed: %v", err))
+	}
+
+	app.Logger.Info("✅ gRPC server shut down successfully.")
+}
+
 // development
 // config.yaml,dockerfile,docker-compose,...
 
@@ -1,7 +1,10 @@
 package authentication
 
 import (
+	"github.com/hosseinasadian/chat-application/adapter/email"
 	"github.com/hosseinasadian/chat-application/adapter/redis"
+	"github.com/hosseinasadian/chat-application/adapter/sms"
+	"github.com/hosseinasadian/chat-application/pkg/grpcserver"
 	"github.com/hosseinasadian/chat-application/pkg/httpserver"
 	authService "github.com/hosseinasadian/chat-application/service/authentication/service"
 	"time"
@@ -10,6 +13,10 @@ import (
 type Config struct {
 	TotalShutdownTimeout time.Duration      `koanf:"total_shutdown_timeout"`
 	HTTPServer           httpserver.Config  `koanf:"http_server"`
+	AdminHTTPServer      httpserver.Config  `koanf:"admin_http_server"`
+	GRPCServer           grpcserver.Config  `koanf:"grpc_server"`
 	AuthService          authService.Config `koanf:"auth_service"`
 	Redis                redis.Config       `koanf:"redis"`
+	Email                email.Config       `koanf:"email"`
+	SMS                  sms.Config         `koanf:"sms"`
 }
@@ -0,0 +1,172 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-chi/httprate"
+
+	"github.com/hosseinasadian/chat-application/contract/goproto/authentication"
+	"github.com/hosseinasadian/chat-application/service/authentication/service"
+	"google.golang.org/grpc"
+)
+
+type Handler struct {
+	authentication.UnimplementedAuthenticationServiceServer
+	AuthSvc service.Service
+
+	loginLimiter AttemptLimiter
+	ipLimiter    AttemptLimiter
+}
+
+// New limits the login calls like the HTTP routes, loginLimiter should share
+// its counter with the login rate limiter of the HTTP handler.
+func New(authSvc service.Service, loginLimiter AttemptLimiter) Handler {
+	return Handler{
+		AuthSvc:      authSvc,
+		loginLimiter: loginLimiter,
+		ipLimiter:    NewAttemptLimiter(10, time.Minute, httprate.NewLocalLimitCounter(time.Minute)),
+	}
+}
+
+func (h Handler) Register(server *grpc.Server) {
+	authentication.RegisterAuthenticationServiceServer(server, h)
+}
+
+func (h Handler) SendOtp(ctx context.Context, req *authentication.SendOtpRequest) (*authentication.SendOtpResponse, error) {
+	res, err := h.AuthSvc.SendOtp(service.SendOtpRequest{
+		Phone:             req.GetPhone(),
+		ChallengeResponse: req.GetChallengeResponse(),
+		Client:            clientFrom(ctx),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := &authentication.SendOtpResponse{Message: res.Message, ChallengeRequired: res.ChallengeRequired}
+	if res.Challenge != nil {
+		out.Challenge = &authentication.StepUpChallenge{Type: res.Challenge.Type, Data: res.Challenge.Data}
+	}
+
+	return out, nil
+}
+
+func (h Handler) VerifyOtp(ctx context.Context, req *authentication.VerifyOtpRequest) (*authentication.VerifyOtpResponse, error) {
+	res, err := h.AuthSvc.VerifyOtp(service.VerifyOtpRequest{
+		Phone:        req.GetPhone(),
+		Otp:          req.GetOtp(),
+		DeviceID:     req.GetDeviceId(),
+		DeviceSecret: req.GetDeviceSecret(),
+		Client:       clientFrom(ctx),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return verifyOtpResponse(res), nil
+}
+
+func (h Handler) CompleteDeviceApproval(ctx context.Context, req *authentication.CompleteDeviceApprovalRequest) (*authentication.VerifyOtpResponse, error) {
+	res, err := h.AuthSvc.CompleteDeviceApproval(service.CompleteDeviceApprovalRequest{
+		ApprovalToken: req.GetApprovalToken(),
+		Client:        clientFrom(ctx),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return verifyOtpResponse(res), nil
+}
+
+func verifyOtpResponse(res service.VerifyOtpResponse) *authentication.VerifyOtpResponse {
+	if res.MFARequired {
+		return &authentication.VerifyOtpResponse{
+			MfaRequired: true,
+			MfaToken:    res.MFAToken,
+		}
+	}
+
+	if res.ApprovalRequired {
+		return &authentication.VerifyOtpResponse{
+			ApprovalRequired: true,
+			ApprovalToken:    res.ApprovalToken,
+		}
+	}
+
+	return &authentication.VerifyOtpResponse{
+		Tokens: &authentication.TokenPair{
+			AccessToken:  res.AccessToken,
+			RefreshToken: res.RefreshToken,
+			DeviceId:     res.DeviceID,
+			DeviceSecret: res.DeviceSecret,
+		},
+	}
+}
+
+func (h Handler) VerifyMfa(ctx context.Context, req *authentication.VerifyMfaRequest) (*authentication.TokenPair, error) {
+	res, err := h.AuthSvc.VerifyMFA(service.VerifyMFARequest{
+		MFAToken: req.GetMfaToken(),
+		Code:     req.GetCode(),
+		Client:   clientFrom(ctx),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &authentication.TokenPair{
+		AccessToken:  res.AccessToken,
+		RefreshToken: res.RefreshToken,
+		DeviceId:     res.DeviceID,
+		DeviceSecret: res.DeviceSecret,
+	}, nil
+}
+
+func (h Handler) RefreshToken(ctx context.Context, req *authentication.RefreshTokenRequest) (*authentication.TokenPair, error) {
+	res, err := h.AuthSvc.RefreshToken(service.RefreshRequest{
+		RefreshToken: req.GetRefreshToken(),
+		DeviceSecret: req.GetDeviceSecret(),
+		Client:       clientFrom(ctx),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &authentication.TokenPair{
+		AccessToken:  res.AccessToken,
+		RefreshToken: res.RefreshToken,
+		DeviceId:     res.DeviceID,
+		DeviceSecret: res.DeviceSecret,
+	}, nil
+}
+
+func (h Handler) ValidateToken(ctx context.Context, req *authentication.ValidateTokenRequest) (*authentication.ValidateTokenResponse, error) {
+	res, err := h.AuthSvc.ValidateToken(service.ValidateTokenRequest{
+		Token: req.GetToken(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &authentication.ValidateTokenResponse{
+		Valid:     res.Valid,
+		Kind:      string(res.Principal.Kind),
+		Subject:   res.Principal.Subject,
+		Phone:     res.Principal.Phone,
+		DeviceId:  res.Principal.DeviceID,
+		OwnerId:   res.Principal.OwnerID,
+		Scopes:    res.Principal.Scopes,
+		ExpiresAt: res.ExpiresAt,
+	}, nil
+}
+
+func (h Handler) Logout(ctx context.Context, req *authentication.LogoutRequest) (*authentication.LogoutResponse, error) {
+	res, err := h.AuthSvc.Logout(service.LogoutRequest{
+		Principal: principalFrom(ctx),
+		Client:    clientFrom(ctx),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &authentication.LogoutResponse{Message: res.Message}, nil
+}
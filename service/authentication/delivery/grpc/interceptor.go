@@ -0,0 +1,63 @@
+package grpc
+
+import (
+	"context"
+	"net"
+
+	"github.com/hosseinasadian/chat-application/contract/goproto/authentication"
+	"github.com/hosseinasadian/chat-application/service/authentication/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+type principalKey struct{}
+
+// authenticatedMethods are the calls that need a principal, the gRPC
+// counterpart of the routes behind AuthMiddleware.
+var authenticatedMethods = map[string]bool{
+	authentication.AuthenticationService_Logout_FullMethodName: true,
+}
+
+func (h Handler) AuthInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if !authenticatedMethods[info.FullMethod] {
+		return handler(ctx, req)
+	}
+
+	var authHeader string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			authHeader = values[0]
+		}
+	}
+
+	principal, err := h.AuthSvc.Authenticate(authHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(context.WithValue(ctx, principalKey{}, principal), req)
+}
+
+func principalFrom(ctx context.Context) service.Principal {
+	principal, _ := ctx.Value(principalKey{}).(service.Principal)
+	return principal
+}
+
+// clientFrom reads the caller address and user agent for the audit log.
+func clientFrom(ctx context.Context) service.ClientInfo {
+	var client service.ClientInfo
+	if p, ok := peer.FromContext(ctx); ok {
+		client.IP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(client.IP); err == nil {
+			client.IP = host
+		}
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if agents := md.Get("user-agent"); len(agents) > 0 {
+			client.UserAgent = agents[0]
+		}
+	}
+
+	return client
+}
@@ -0,0 +1,62 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/hosseinasadian/chat-application/contract/goproto/authentication"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// proofOfWorkRoutes names the calls after the HTTP routes they share the
+// proof_of_work.routes setting with.
+var proofOfWorkRoutes = map[string]string{
+	authentication.AuthenticationService_SendOtp_FullMethodName:                "/send-otp",
+	authentication.AuthenticationService_VerifyOtp_FullMethodName:              "/verify-otp",
+	authentication.AuthenticationService_VerifyMfa_FullMethodName:              "/verify-mfa",
+	authentication.AuthenticationService_CompleteDeviceApproval_FullMethodName: "/device-approval",
+	authentication.AuthenticationService_RefreshToken_FullMethodName:           "/refresh-token",
+}
+
+// The solution is sent in ProofOfWorkMetadata, "<puzzle>:<nonce>" as in the
+// X-Proof-Of-Work header. A call without a valid one fails and gets a puzzle
+// in the ProofOfWorkPuzzleMetadata and ProofOfWorkDifficultyMetadata
+// trailers.
+const (
+	ProofOfWorkMetadata           = "x-proof-of-work"
+	ProofOfWorkPuzzleMetadata     = "x-proof-of-work-puzzle"
+	ProofOfWorkDifficultyMetadata = "x-proof-of-work-difficulty"
+)
+
+// ProofOfWorkInterceptor holds back the calls whose routes are configured for
+// a proof of work, the gRPC counterpart of ProofOfWorkMiddleware.
+func (h Handler) ProofOfWorkInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	route, ok := proofOfWorkRoutes[info.FullMethod]
+	if !ok || !h.AuthSvc.ProofOfWorkRequired(route) {
+		return handler(ctx, req)
+	}
+
+	var solution string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(ProofOfWorkMetadata); len(values) > 0 {
+			solution = values[0]
+		}
+	}
+
+	client := clientFrom(ctx)
+	vErr := h.AuthSvc.VerifyProofOfWork(route, client, solution)
+	if vErr == nil {
+		return handler(ctx, req)
+	}
+
+	challenge, cErr := h.AuthSvc.ProofOfWorkChallenge(route, client)
+	if cErr != nil {
+		return nil, cErr
+	}
+	_ = grpc.SetTrailer(ctx, metadata.Pairs(
+		ProofOfWorkPuzzleMetadata, challenge.Data["puzzle"],
+		ProofOfWorkDifficultyMetadata, challenge.Data["difficulty"],
+	))
+
+	return nil, vErr
+}
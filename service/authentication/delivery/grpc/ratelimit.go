@@ -0,0 +1,96 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-chi/httprate"
+	"github.com/hosseinasadian/chat-application/contract/goproto/authentication"
+	"github.com/hosseinasadian/chat-application/pkg/richerror"
+	"google.golang.org/grpc"
+)
+
+// loginMethods are the calls limited per peer IP, the gRPC counterpart of the
+// routes behind httprate.LimitByIP.
+var loginMethods = map[string]bool{
+	authentication.AuthenticationService_SendOtp_FullMethodName:                true,
+	authentication.AuthenticationService_VerifyOtp_FullMethodName:              true,
+	authentication.AuthenticationService_VerifyMfa_FullMethodName:              true,
+	authentication.AuthenticationService_CompleteDeviceApproval_FullMethodName: true,
+	authentication.AuthenticationService_RefreshToken_FullMethodName:           true,
+}
+
+// AttemptLimiter counts attempts of a key over a sliding window the same way
+// httprate does, so a counter shared with the HTTP routes locks out both.
+type AttemptLimiter struct {
+	counter httprate.LimitCounter
+	limit   int
+	window  time.Duration
+}
+
+func NewAttemptLimiter(limit int, window time.Duration, counter httprate.LimitCounter) AttemptLimiter {
+	counter.Config(limit, window)
+	return AttemptLimiter{counter: counter, limit: limit, window: window}
+}
+
+// exceeded reports whether key has no attempts left.
+func (l AttemptLimiter) exceeded(key string) (bool, error) {
+	now := time.Now().UTC()
+	current := now.Truncate(l.window)
+
+	currCount, prevCount, err := l.counter.Get(key, current, current.Add(-l.window))
+	if err != nil {
+		return false, err
+	}
+
+	rate := float64(prevCount)*float64(l.window-now.Sub(current))/float64(l.window) + float64(currCount)
+	return rate+1 > float64(l.limit), nil
+}
+
+func (l AttemptLimiter) count(key string) error {
+	return l.counter.Increment(key, time.Now().UTC().Truncate(l.window))
+}
+
+// RateLimitInterceptor limits the login calls per peer IP and locks out a
+// phone number after too many wrong codes, sharing the lockout with the HTTP
+// routes.
+func (h Handler) RateLimitInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	const op = "authentication.delivery.grpc.RateLimitInterceptor"
+
+	if !loginMethods[info.FullMethod] {
+		return handler(ctx, req)
+	}
+
+	ip := clientFrom(ctx).IP
+	if over, err := h.ipLimiter.exceeded(ip); err != nil {
+		return nil, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	} else if over {
+		return nil, richerror.New(op).WithKind(richerror.KindTooManyRequests).WithMessage("Too many requests")
+	}
+	if err := h.ipLimiter.count(ip); err != nil {
+		return nil, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	// only one time codes are counted here, MFA challenges count their own
+	// attempts and the other calls take tokens too long to guess
+	key := ""
+	if r, ok := req.(*authentication.VerifyOtpRequest); ok {
+		key = h.AuthSvc.OtpAttemptsKey(r.GetPhone())
+	}
+	if key == "" {
+		return handler(ctx, req)
+	}
+
+	if over, err := h.loginLimiter.exceeded(key); err != nil {
+		return nil, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	} else if over {
+		return nil, richerror.New(op).WithKind(richerror.KindTooManyRequests).WithMessage("Too many attempts")
+	}
+
+	res, err := handler(ctx, req)
+	if err != nil {
+		_ = h.loginLimiter.count(key)
+	}
+
+	return res, err
+}
@@ -0,0 +1,101 @@
+package http
+
+import (
+	"github.com/hosseinasadian/chat-application/pkg/httpmsg"
+	"github.com/hosseinasadian/chat-application/pkg/httpresponse"
+	"github.com/hosseinasadian/chat-application/service/authentication/service"
+	"net/http"
+)
+
+func (h Handler) DeleteAccountHandler(w http.ResponseWriter, r *http.Request) {
+	res, dErr := h.AuthSvc.DeleteAccount(service.DeleteAccountRequest{
+		Principal: principalFrom(r),
+	})
+	if dErr != nil {
+		msg, code := httpmsg.Error(dErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetStatus(w, http.StatusAccepted)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) CancelAccountDeletionHandler(w http.ResponseWriter, r *http.Request) {
+	res, cErr := h.AuthSvc.CancelAccountDeletion(service.CancelAccountDeletionRequest{
+		Principal: principalFrom(r),
+	})
+	if cErr != nil {
+		msg, code := httpmsg.Error(cErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) RequestAccountExportHandler(w http.ResponseWriter, r *http.Request) {
+	res, eErr := h.AuthSvc.RequestAccountExport(service.RequestAccountExportRequest{
+		Principal: principalFrom(r),
+	})
+	if eErr != nil {
+		msg, code := httpmsg.Error(eErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetStatus(w, http.StatusAccepted)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) GetAccountExportHandler(w http.ResponseWriter, r *http.Request) {
+	res, gErr := h.AuthSvc.GetAccountExport(service.GetAccountExportRequest{
+		Principal: principalFrom(r),
+	})
+	if gErr != nil {
+		msg, code := httpmsg.Error(gErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) DownloadAccountExportHandler(w http.ResponseWriter, r *http.Request) {
+	res, dErr := h.AuthSvc.DownloadAccountExport(service.DownloadAccountExportRequest{
+		Principal: principalFrom(r),
+	})
+	if dErr != nil {
+		msg, code := httpmsg.Error(dErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Disposition", `attachment; filename="`+res.FileName+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+	http.ServeFile(w, r, res.Path)
+}
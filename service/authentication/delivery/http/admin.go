@@ -0,0 +1,290 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"github.com/hosseinasadian/chat-application/pkg/httpmsg"
+	"github.com/hosseinasadian/chat-application/pkg/httpresponse"
+	"github.com/hosseinasadian/chat-application/service/authentication/service"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// AdminHandler serves the operator API. It runs on a server of its own so it
+// can be kept off the public network; every call needs the access token of a
+// user holding an admin role.
+type AdminHandler struct {
+	Handler
+}
+
+func NewAdmin(authSvc service.Service) AdminHandler {
+	return AdminHandler{Handler: Handler{AuthSvc: authSvc}}
+}
+
+func (h AdminHandler) LookupUserHandler(w http.ResponseWriter, r *http.Request) {
+	res, lErr := h.AuthSvc.LookupUser(service.AdminLookupUserRequest{
+		Principal: principalFrom(r),
+		UserID:    chi.URLParam(r, "userID"),
+		Phone:     r.URL.Query().Get("phone"),
+	})
+	if lErr != nil {
+		msg, code := httpmsg.Error(lErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h AdminHandler) RevokeUserSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	res, rErr := h.AuthSvc.RevokeUserSessions(service.AdminRevokeSessionsRequest{
+		Principal: principalFrom(r),
+		UserID:    chi.URLParam(r, "userID"),
+	})
+	if rErr != nil {
+		msg, code := httpmsg.Error(rErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h AdminHandler) SetRoleHandler(w http.ResponseWriter, r *http.Request) {
+	var req service.AdminSetRoleRequest
+	if dErr := json.NewDecoder(r.Body).Decode(&req); dErr != nil {
+		msg, code := httpmsg.Error(dErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+	req.Principal = principalFrom(r)
+	req.UserID = chi.URLParam(r, "userID")
+
+	res, sErr := h.AuthSvc.SetAdminRole(req)
+	if sErr != nil {
+		msg, code := httpmsg.Error(sErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h AdminHandler) ListRolesHandler(w http.ResponseWriter, r *http.Request) {
+	res, lErr := h.AuthSvc.ListAdminRoles(service.AdminListRolesRequest{
+		Principal: principalFrom(r),
+	})
+	if lErr != nil {
+		msg, code := httpmsg.Error(lErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h AdminHandler) UnlockLoginHandler(w http.ResponseWriter, r *http.Request) {
+	var req service.AdminUnlockLoginRequest
+	if dErr := json.NewDecoder(r.Body).Decode(&req); dErr != nil {
+		msg, code := httpmsg.Error(dErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+	req.Principal = principalFrom(r)
+
+	res, uErr := h.AuthSvc.UnlockLogin(req)
+	if uErr != nil {
+		msg, code := httpmsg.Error(uErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h AdminHandler) ListBansHandler(w http.ResponseWriter, r *http.Request) {
+	res, lErr := h.AuthSvc.ListPhoneBans(service.AdminListPhoneBansRequest{
+		Principal: principalFrom(r),
+	})
+	if lErr != nil {
+		msg, code := httpmsg.Error(lErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h AdminHandler) BanPhoneHandler(w http.ResponseWriter, r *http.Request) {
+	var req service.AdminBanPhoneRequest
+	if dErr := json.NewDecoder(r.Body).Decode(&req); dErr != nil {
+		msg, code := httpmsg.Error(dErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+	req.Principal = principalFrom(r)
+
+	res, bErr := h.AuthSvc.BanPhone(req)
+	if bErr != nil {
+		msg, code := httpmsg.Error(bErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h AdminHandler) UnbanPhoneHandler(w http.ResponseWriter, r *http.Request) {
+	res, uErr := h.AuthSvc.UnbanPhone(service.AdminUnbanPhoneRequest{
+		Principal: principalFrom(r),
+		Phone:     chi.URLParam(r, "phone"),
+	})
+	if uErr != nil {
+		msg, code := httpmsg.Error(uErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h AdminHandler) ListReportsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req := service.AdminListReportsRequest{
+		Principal: principalFrom(r),
+		Before:    query.Get("before"),
+	}
+	if raw := query.Get("limit"); raw != "" {
+		limit, cErr := strconv.Atoi(raw)
+		if cErr != nil {
+			httpresponse.SetJsonContentType(w)
+			httpresponse.SetStatus(w, http.StatusBadRequest)
+			httpresponse.SetMessage(w, map[string]string{
+				"error": "limit must be a number",
+			})
+			return
+		}
+		req.Limit = limit
+	}
+
+	res, lErr := h.AuthSvc.ListReports(req)
+	if lErr != nil {
+		msg, code := httpmsg.Error(lErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h AdminHandler) QueryAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req := service.QueryAuditLogRequest{
+		Principal: principalFrom(r),
+		UserID:    query.Get("user_id"),
+		ActorID:   query.Get("actor_id"),
+		Phone:     query.Get("phone"),
+		Type:      query.Get("type"),
+		IP:        query.Get("ip"),
+		DeviceID:  query.Get("device_id"),
+		Before:    query.Get("before"),
+	}
+
+	var err error
+	if raw := query.Get("limit"); raw != "" {
+		if req.Limit, err = strconv.Atoi(raw); err != nil {
+			httpresponse.SetJsonContentType(w)
+			httpresponse.SetStatus(w, http.StatusBadRequest)
+			httpresponse.SetMessage(w, map[string]string{
+				"error": "limit must be a number",
+			})
+			return
+		}
+	}
+	for name, value := range map[string]*time.Time{"since": &req.Since, "until": &req.Until} {
+		raw := query.Get(name)
+		if raw == "" {
+			continue
+		}
+		if *value, err = time.Parse(time.RFC3339, raw); err != nil {
+			httpresponse.SetJsonContentType(w)
+			httpresponse.SetStatus(w, http.StatusBadRequest)
+			httpresponse.SetMessage(w, map[string]string{
+				"error": name + " must be an RFC 3339 time",
+			})
+			return
+		}
+	}
+
+	res, qErr := h.AuthSvc.QueryAuditLog(req)
+	if qErr != nil {
+		msg, code := httpmsg.Error(qErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
@@ -0,0 +1,145 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"github.com/hosseinasadian/chat-application/pkg/httpmsg"
+	"github.com/hosseinasadian/chat-application/pkg/httpresponse"
+	"github.com/hosseinasadian/chat-application/service/authentication/service"
+	"net/http"
+)
+
+func (h Handler) CreateBotHandler(w http.ResponseWriter, r *http.Request) {
+	var req service.CreateBotRequest
+	if dErr := json.NewDecoder(r.Body).Decode(&req); dErr != nil {
+		msg, code := httpmsg.Error(dErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+	req.Principal = principalFrom(r)
+
+	res, sErr := h.AuthSvc.CreateBot(req)
+	if sErr != nil {
+		msg, code := httpmsg.Error(sErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetStatus(w, http.StatusCreated)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) ListBotsHandler(w http.ResponseWriter, r *http.Request) {
+	res, sErr := h.AuthSvc.ListBots(service.ListBotsRequest{
+		Principal: principalFrom(r),
+	})
+	if sErr != nil {
+		msg, code := httpmsg.Error(sErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) DeleteBotHandler(w http.ResponseWriter, r *http.Request) {
+	res, sErr := h.AuthSvc.DeleteBot(service.DeleteBotRequest{
+		Principal: principalFrom(r),
+		BotID:     chi.URLParam(r, "botID"),
+	})
+	if sErr != nil {
+		msg, code := httpmsg.Error(sErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) CreateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var req service.CreateAPIKeyRequest
+	if dErr := json.NewDecoder(r.Body).Decode(&req); dErr != nil {
+		msg, code := httpmsg.Error(dErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+	req.Principal = principalFrom(r)
+	req.BotID = chi.URLParam(r, "botID")
+
+	res, sErr := h.AuthSvc.CreateAPIKey(req)
+	if sErr != nil {
+		msg, code := httpmsg.Error(sErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetStatus(w, http.StatusCreated)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) ListAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	res, sErr := h.AuthSvc.ListAPIKeys(service.ListAPIKeysRequest{
+		Principal: principalFrom(r),
+		BotID:     chi.URLParam(r, "botID"),
+	})
+	if sErr != nil {
+		msg, code := httpmsg.Error(sErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) RevokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	res, sErr := h.AuthSvc.RevokeAPIKey(service.RevokeAPIKeyRequest{
+		Principal: principalFrom(r),
+		BotID:     chi.URLParam(r, "botID"),
+		KeyID:     chi.URLParam(r, "keyID"),
+	})
+	if sErr != nil {
+		msg, code := httpmsg.Error(sErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
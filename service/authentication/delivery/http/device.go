@@ -0,0 +1,218 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/hosseinasadian/chat-application/pkg/httpmsg"
+	"github.com/hosseinasadian/chat-application/pkg/httpresponse"
+	"github.com/hosseinasadian/chat-application/service/authentication/service"
+	"net/http"
+	"time"
+)
+
+func (h Handler) ListDevicesHandler(w http.ResponseWriter, r *http.Request) {
+	res, lErr := h.AuthSvc.ListDevices(service.ListDevicesRequest{
+		Principal: principalFrom(r),
+	})
+	if lErr != nil {
+		msg, code := httpmsg.Error(lErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) UpdateDeviceHandler(w http.ResponseWriter, r *http.Request) {
+	var req service.UpdateDeviceRequest
+	if dErr := json.NewDecoder(r.Body).Decode(&req); dErr != nil {
+		msg, code := httpmsg.Error(dErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+	req.Principal = principalFrom(r)
+	req.DeviceID = chi.URLParam(r, "deviceID")
+
+	res, uErr := h.AuthSvc.UpdateDevice(req)
+	if uErr != nil {
+		msg, code := httpmsg.Error(uErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) RemoveDeviceHandler(w http.ResponseWriter, r *http.Request) {
+	res, rErr := h.AuthSvc.RemoveDevice(service.RemoveDeviceRequest{
+		Principal: principalFrom(r),
+		DeviceID:  chi.URLParam(r, "deviceID"),
+	})
+	if rErr != nil {
+		msg, code := httpmsg.Error(rErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) UpdateDeviceSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	var req service.UpdateDeviceSettingsRequest
+	if dErr := json.NewDecoder(r.Body).Decode(&req); dErr != nil {
+		msg, code := httpmsg.Error(dErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+	req.Principal = principalFrom(r)
+
+	res, uErr := h.AuthSvc.UpdateDeviceSettings(req)
+	if uErr != nil {
+		msg, code := httpmsg.Error(uErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) DecideDeviceApprovalHandler(w http.ResponseWriter, r *http.Request) {
+	var req service.DecideDeviceApprovalRequest
+	if dErr := json.NewDecoder(r.Body).Decode(&req); dErr != nil {
+		msg, code := httpmsg.Error(dErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+	req.Principal = principalFrom(r)
+	req.ApprovalID = chi.URLParam(r, "approvalID")
+
+	res, aErr := h.AuthSvc.DecideDeviceApproval(req)
+	if aErr != nil {
+		msg, code := httpmsg.Error(aErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) CompleteDeviceApprovalHandler(w http.ResponseWriter, r *http.Request) {
+	var req service.CompleteDeviceApprovalRequest
+	if dErr := json.NewDecoder(r.Body).Decode(&req); dErr != nil {
+		msg, code := httpmsg.Error(dErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+	req.Client = h.clientFrom(r)
+
+	res, cErr := h.AuthSvc.CompleteDeviceApproval(req)
+	if cErr != nil {
+		msg, code := httpmsg.Error(cErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+// NotificationsHandler streams notifications as server-sent events until the
+// client goes away.
+func (h Handler) NotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, http.StatusInternalServerError)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": "Streaming is not supported",
+		})
+		return
+	}
+
+	notifications, nErr := h.AuthSvc.Notifications(r.Context(), service.NotificationsRequest{
+		Principal: principalFrom(r),
+	})
+	if nErr != nil {
+		msg, code := httpmsg.Error(nErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// a comment now and then keeps proxies from closing an idle stream
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			_, _ = fmt.Fprint(w, ": keep-alive\n\n")
+		case notification, open := <-notifications:
+			if !open {
+				return
+			}
+			data, err := json.Marshal(notification)
+			if err != nil {
+				continue
+			}
+			_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", notification.Type, data)
+		}
+		flusher.Flush()
+	}
+}
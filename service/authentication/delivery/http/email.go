@@ -0,0 +1,186 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/hosseinasadian/chat-application/pkg/httpmsg"
+	"github.com/hosseinasadian/chat-application/pkg/httpresponse"
+	"github.com/hosseinasadian/chat-application/service/authentication/service"
+	"net/http"
+)
+
+func (h Handler) LinkEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var req service.LinkEmailRequest
+	if dErr := json.NewDecoder(r.Body).Decode(&req); dErr != nil {
+		msg, code := httpmsg.Error(dErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+	req.Principal = principalFrom(r)
+
+	res, lErr := h.AuthSvc.LinkEmail(req)
+	if lErr != nil {
+		msg, code := httpmsg.Error(lErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var req service.VerifyEmailRequest
+	if dErr := json.NewDecoder(r.Body).Decode(&req); dErr != nil {
+		msg, code := httpmsg.Error(dErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+	req.Principal = principalFrom(r)
+
+	res, vErr := h.AuthSvc.VerifyEmail(req)
+	if vErr != nil {
+		if h.LoginRateLimiter.OnLimit(w, r, service.EmailVerifyAttemptsKey(req.Principal.Subject)) {
+			httpresponse.SetJsonContentType(w)
+			httpresponse.SetStatus(w, http.StatusTooManyRequests)
+			httpresponse.SetMessage(w, map[string]string{
+				"error": "Too many attempts",
+			})
+			return
+		}
+
+		msg, code := httpmsg.Error(vErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) UnlinkEmailHandler(w http.ResponseWriter, r *http.Request) {
+	res, uErr := h.AuthSvc.UnlinkEmail(service.UnlinkEmailRequest{
+		Principal: principalFrom(r),
+	})
+	if uErr != nil {
+		msg, code := httpmsg.Error(uErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) SendEmailLoginHandler(w http.ResponseWriter, r *http.Request) {
+	var req service.SendEmailLoginRequest
+	if dErr := json.NewDecoder(r.Body).Decode(&req); dErr != nil {
+		msg, code := httpmsg.Error(dErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	res, sErr := h.AuthSvc.SendEmailLogin(req)
+	if sErr != nil {
+		msg, code := httpmsg.Error(sErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) VerifyEmailOtpHandler(w http.ResponseWriter, r *http.Request) {
+	var req service.VerifyEmailOtpRequest
+	if dErr := json.NewDecoder(r.Body).Decode(&req); dErr != nil {
+		msg, code := httpmsg.Error(dErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	req.Client = h.clientFrom(r)
+
+	res, vErr := h.AuthSvc.VerifyEmailOtp(req)
+	if vErr != nil {
+		if h.LoginRateLimiter.OnLimit(w, r, h.AuthSvc.EmailOtpAttemptsKey(req.Email)) {
+			httpresponse.SetJsonContentType(w)
+			httpresponse.SetStatus(w, http.StatusTooManyRequests)
+			httpresponse.SetMessage(w, map[string]string{
+				"error": "Too many attempts",
+			})
+			return
+		}
+
+		msg, code := httpmsg.Error(vErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) VerifyMagicLinkHandler(w http.ResponseWriter, r *http.Request) {
+	var req service.VerifyMagicLinkRequest
+	if dErr := json.NewDecoder(r.Body).Decode(&req); dErr != nil {
+		msg, code := httpmsg.Error(dErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	req.Client = h.clientFrom(r)
+
+	res, vErr := h.AuthSvc.VerifyMagicLink(req)
+	if vErr != nil {
+		msg, code := httpmsg.Error(vErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
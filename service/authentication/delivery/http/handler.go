@@ -7,6 +7,7 @@ import (
 	"github.com/hosseinasadian/chat-application/pkg/httpmsg"
 	"github.com/hosseinasadian/chat-application/pkg/httpresponse"
 	"github.com/hosseinasadian/chat-application/service/authentication/service"
+	"net"
 	"net/http"
 )
 
@@ -34,6 +35,8 @@ func (h Handler) SendOtpHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req.Client = h.clientFrom(r)
+
 	res, sErr := h.AuthSvc.SendOtp(req)
 	if sErr != nil {
 		msg, code := httpmsg.Error(sErr)
@@ -61,9 +64,11 @@ func (h Handler) VerifyOtpHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req.Client = h.clientFrom(r)
+
 	res, vErr := h.AuthSvc.VerifyOtp(req)
 	if vErr != nil {
-		if h.LoginRateLimiter.OnLimit(w, r, "otp_attempts:"+req.Phone) {
+		if h.LoginRateLimiter.OnLimit(w, r, h.AuthSvc.OtpAttemptsKey(req.Phone)) {
 			httpresponse.SetJsonContentType(w)
 			httpresponse.SetStatus(w, http.StatusTooManyRequests)
 			httpresponse.SetMessage(w, map[string]string{
@@ -96,6 +101,8 @@ func (h Handler) RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req.Client = h.clientFrom(r)
+
 	res, rErr := h.AuthSvc.RefreshToken(req)
 	if rErr != nil {
 		msg, code := httpmsg.Error(rErr)
@@ -112,7 +119,7 @@ func (h Handler) RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
 
 func (h Handler) MeHandler(w http.ResponseWriter, r *http.Request) {
 	res, err := h.AuthSvc.Me(service.MeRequest{
-		Claims: r.Context().Value("claims"),
+		Principal: principalFrom(r),
 	})
 
 	if err != nil {
@@ -131,7 +138,8 @@ func (h Handler) MeHandler(w http.ResponseWriter, r *http.Request) {
 
 func (h Handler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	res, err := h.AuthSvc.Logout(service.LogoutRequest{
-		Claims: r.Context().Value("claims"),
+		Principal: principalFrom(r),
+		Client:    h.clientFrom(r),
 	})
 
 	if err != nil {
@@ -150,16 +158,37 @@ func (h Handler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
 func (h Handler) AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
-		claims, pErr := h.AuthSvc.ParseToken(authHeader)
-		if pErr != nil {
-			msg, code := httpmsg.Error(pErr)
+		principal, aErr := h.AuthSvc.Authenticate(authHeader)
+		if aErr != nil {
+			msg, code := httpmsg.Error(aErr)
 			httpresponse.SetStatus(w, code)
 			httpresponse.SetMessage(w, map[string]string{
 				"error": msg,
 			})
 			return
 		}
-		ctx := context.WithValue(r.Context(), "claims", claims)
+		ctx := context.WithValue(r.Context(), "principal", principal)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+func principalFrom(r *http.Request) service.Principal {
+	principal, _ := r.Context().Value("principal").(service.Principal)
+	return principal
+}
+
+// clientFrom reads the caller address the same way the IP rate limits do,
+// the user agent and the location set by the edge proxy.
+func (h Handler) clientFrom(r *http.Request) service.ClientInfo {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	client := service.ClientInfo{IP: ip, UserAgent: r.UserAgent()}
+	if header := h.AuthSvc.LocationHeader(); header != "" {
+		client.Location = r.Header.Get(header)
+	}
+
+	return client
+}
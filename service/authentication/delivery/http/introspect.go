@@ -0,0 +1,57 @@
+package http
+
+import (
+	"github.com/hosseinasadian/chat-application/pkg/httpmsg"
+	"github.com/hosseinasadian/chat-application/pkg/httpresponse"
+	"github.com/hosseinasadian/chat-application/service/authentication/service"
+	"net/http"
+)
+
+// IntrospectHandler implements RFC 7662 token introspection. The request is
+// form encoded with "token" and an optional "token_type_hint".
+func (h Handler) IntrospectHandler(w http.ResponseWriter, r *http.Request) {
+	if pErr := r.ParseForm(); pErr != nil {
+		msg, code := httpmsg.Error(pErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	res, iErr := h.AuthSvc.Introspect(service.IntrospectRequest{
+		Token:         r.PostForm.Get("token"),
+		TokenTypeHint: r.PostForm.Get("token_type_hint"),
+	})
+	if iErr != nil {
+		msg, code := httpmsg.Error(iErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) ClientAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientID, clientSecret, _ := r.BasicAuth()
+		if aErr := h.AuthSvc.AuthenticateClient(clientID, clientSecret); aErr != nil {
+			msg, code := httpmsg.Error(aErr)
+			w.Header().Set("WWW-Authenticate", `Basic realm="introspection"`)
+			httpresponse.SetJsonContentType(w)
+			httpresponse.SetStatus(w, code)
+			httpresponse.SetMessage(w, map[string]string{
+				"error": msg,
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
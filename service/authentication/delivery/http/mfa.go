@@ -0,0 +1,113 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/hosseinasadian/chat-application/pkg/httpmsg"
+	"github.com/hosseinasadian/chat-application/pkg/httpresponse"
+	"github.com/hosseinasadian/chat-application/service/authentication/service"
+	"net/http"
+)
+
+func (h Handler) VerifyMFAHandler(w http.ResponseWriter, r *http.Request) {
+	var req service.VerifyMFARequest
+	if dErr := json.NewDecoder(r.Body).Decode(&req); dErr != nil {
+		msg, code := httpmsg.Error(dErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	req.Client = h.clientFrom(r)
+
+	res, vErr := h.AuthSvc.VerifyMFA(req)
+	if vErr != nil {
+		msg, code := httpmsg.Error(vErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) EnrollTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	res, eErr := h.AuthSvc.EnrollTOTP(service.EnrollTOTPRequest{
+		Principal: principalFrom(r),
+	})
+	if eErr != nil {
+		msg, code := httpmsg.Error(eErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) ConfirmTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	var req service.ConfirmTOTPRequest
+	if dErr := json.NewDecoder(r.Body).Decode(&req); dErr != nil {
+		msg, code := httpmsg.Error(dErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+	req.Principal = principalFrom(r)
+
+	res, cErr := h.AuthSvc.ConfirmTOTP(req)
+	if cErr != nil {
+		msg, code := httpmsg.Error(cErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) DisableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	var req service.DisableTOTPRequest
+	if dErr := json.NewDecoder(r.Body).Decode(&req); dErr != nil {
+		msg, code := httpmsg.Error(dErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+	req.Principal = principalFrom(r)
+
+	res, dErr := h.AuthSvc.DisableTOTP(req)
+	if dErr != nil {
+		msg, code := httpmsg.Error(dErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
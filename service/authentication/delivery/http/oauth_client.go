@@ -0,0 +1,77 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"github.com/hosseinasadian/chat-application/pkg/httpmsg"
+	"github.com/hosseinasadian/chat-application/pkg/httpresponse"
+	"github.com/hosseinasadian/chat-application/service/authentication/service"
+	"net/http"
+)
+
+func (h Handler) CreateOAuthClientHandler(w http.ResponseWriter, r *http.Request) {
+	var req service.CreateOAuthClientRequest
+	if dErr := json.NewDecoder(r.Body).Decode(&req); dErr != nil {
+		msg, code := httpmsg.Error(dErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+	req.Principal = principalFrom(r)
+
+	res, cErr := h.AuthSvc.CreateOAuthClient(req)
+	if cErr != nil {
+		msg, code := httpmsg.Error(cErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetStatus(w, http.StatusCreated)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) ListOAuthClientsHandler(w http.ResponseWriter, r *http.Request) {
+	res, lErr := h.AuthSvc.ListOAuthClients(service.ListOAuthClientsRequest{
+		Principal: principalFrom(r),
+	})
+	if lErr != nil {
+		msg, code := httpmsg.Error(lErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) DeleteOAuthClientHandler(w http.ResponseWriter, r *http.Request) {
+	res, dErr := h.AuthSvc.DeleteOAuthClient(service.DeleteOAuthClientRequest{
+		Principal: principalFrom(r),
+		ClientID:  chi.URLParam(r, "clientID"),
+	})
+	if dErr != nil {
+		msg, code := httpmsg.Error(dErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
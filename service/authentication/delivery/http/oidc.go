@@ -0,0 +1,180 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"github.com/hosseinasadian/chat-application/pkg/httpmsg"
+	"github.com/hosseinasadian/chat-application/pkg/httpresponse"
+	"github.com/hosseinasadian/chat-application/service/authentication/service"
+	"net/http"
+)
+
+func (h Handler) DiscoveryHandler(w http.ResponseWriter, r *http.Request) {
+	res, dErr := h.AuthSvc.Discovery()
+	if dErr != nil {
+		msg, code := httpmsg.Error(dErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	res, jErr := h.AuthSvc.JWKS()
+	if jErr != nil {
+		msg, code := httpmsg.Error(jErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+// AuthorizeHandler starts the authorization code flow. The browser is sent to
+// the consent page, or back to the client when the request is rejected.
+func (h Handler) AuthorizeHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	res, aErr := h.AuthSvc.Authorize(service.AuthorizeRequest{
+		ResponseType:        query.Get("response_type"),
+		ClientID:            query.Get("client_id"),
+		RedirectURI:         query.Get("redirect_uri"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		Nonce:               query.Get("nonce"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+	})
+	if aErr != nil {
+		msg, code := httpmsg.Error(aErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	http.Redirect(w, r, res.RedirectTo, http.StatusFound)
+}
+
+func (h Handler) GetConsentHandler(w http.ResponseWriter, r *http.Request) {
+	res, gErr := h.AuthSvc.GetConsent(service.GetConsentRequest{
+		Principal: principalFrom(r),
+		RequestID: chi.URLParam(r, "requestID"),
+	})
+	if gErr != nil {
+		msg, code := httpmsg.Error(gErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) DecideConsentHandler(w http.ResponseWriter, r *http.Request) {
+	var req service.DecideConsentRequest
+	if dErr := json.NewDecoder(r.Body).Decode(&req); dErr != nil {
+		msg, code := httpmsg.Error(dErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+	req.Principal = principalFrom(r)
+	req.RequestID = chi.URLParam(r, "requestID")
+
+	res, cErr := h.AuthSvc.DecideConsent(req)
+	if cErr != nil {
+		msg, code := httpmsg.Error(cErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+// TokenHandler is the OAuth token endpoint. The request is form encoded and
+// the client authenticates with HTTP Basic or with form parameters.
+func (h Handler) TokenHandler(w http.ResponseWriter, r *http.Request) {
+	if pErr := r.ParseForm(); pErr != nil {
+		msg, code := httpmsg.Error(pErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	req := service.TokenRequest{
+		GrantType:    r.PostForm.Get("grant_type"),
+		Code:         r.PostForm.Get("code"),
+		RedirectURI:  r.PostForm.Get("redirect_uri"),
+		CodeVerifier: r.PostForm.Get("code_verifier"),
+		RefreshToken: r.PostForm.Get("refresh_token"),
+		ClientID:     r.PostForm.Get("client_id"),
+		ClientSecret: r.PostForm.Get("client_secret"),
+		Client:       h.clientFrom(r),
+	}
+	if clientID, clientSecret, ok := r.BasicAuth(); ok {
+		req.ClientID, req.ClientSecret = clientID, clientSecret
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	res, tErr := h.AuthSvc.Token(req)
+	if tErr != nil {
+		msg, code := httpmsg.Error(tErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) UserInfoHandler(w http.ResponseWriter, r *http.Request) {
+	res, uErr := h.AuthSvc.UserInfo(service.UserInfoRequest{
+		Principal: principalFrom(r),
+	})
+	if uErr != nil {
+		msg, code := httpmsg.Error(uErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
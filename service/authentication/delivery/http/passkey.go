@@ -0,0 +1,139 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"github.com/hosseinasadian/chat-application/pkg/httpmsg"
+	"github.com/hosseinasadian/chat-application/pkg/httpresponse"
+	"github.com/hosseinasadian/chat-application/service/authentication/service"
+	"net/http"
+)
+
+func (h Handler) BeginPasskeyRegistrationHandler(w http.ResponseWriter, r *http.Request) {
+	res, bErr := h.AuthSvc.BeginPasskeyRegistration(service.BeginPasskeyRegistrationRequest{
+		Principal: principalFrom(r),
+	})
+	if bErr != nil {
+		msg, code := httpmsg.Error(bErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) FinishPasskeyRegistrationHandler(w http.ResponseWriter, r *http.Request) {
+	var req service.FinishPasskeyRegistrationRequest
+	if dErr := json.NewDecoder(r.Body).Decode(&req); dErr != nil {
+		msg, code := httpmsg.Error(dErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+	req.Principal = principalFrom(r)
+
+	res, fErr := h.AuthSvc.FinishPasskeyRegistration(req)
+	if fErr != nil {
+		msg, code := httpmsg.Error(fErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetStatus(w, http.StatusCreated)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) ListPasskeysHandler(w http.ResponseWriter, r *http.Request) {
+	res, lErr := h.AuthSvc.ListPasskeys(service.ListPasskeysRequest{
+		Principal: principalFrom(r),
+	})
+	if lErr != nil {
+		msg, code := httpmsg.Error(lErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) DeletePasskeyHandler(w http.ResponseWriter, r *http.Request) {
+	res, dErr := h.AuthSvc.DeletePasskey(service.DeletePasskeyRequest{
+		Principal: principalFrom(r),
+		PasskeyID: chi.URLParam(r, "passkeyID"),
+	})
+	if dErr != nil {
+		msg, code := httpmsg.Error(dErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) BeginPasskeyLoginHandler(w http.ResponseWriter, r *http.Request) {
+	res, bErr := h.AuthSvc.BeginPasskeyLogin(service.BeginPasskeyLoginRequest{})
+	if bErr != nil {
+		msg, code := httpmsg.Error(bErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) FinishPasskeyLoginHandler(w http.ResponseWriter, r *http.Request) {
+	var req service.FinishPasskeyLoginRequest
+	if dErr := json.NewDecoder(r.Body).Decode(&req); dErr != nil {
+		msg, code := httpmsg.Error(dErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	req.Client = h.clientFrom(r)
+
+	res, fErr := h.AuthSvc.FinishPasskeyLogin(req)
+	if fErr != nil {
+		msg, code := httpmsg.Error(fErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
@@ -0,0 +1,76 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/hosseinasadian/chat-application/pkg/httpmsg"
+	"github.com/hosseinasadian/chat-application/pkg/httpresponse"
+	"github.com/hosseinasadian/chat-application/service/authentication/service"
+	"net/http"
+)
+
+func (h Handler) StartPhoneChangeHandler(w http.ResponseWriter, r *http.Request) {
+	var req service.StartPhoneChangeRequest
+	if dErr := json.NewDecoder(r.Body).Decode(&req); dErr != nil {
+		msg, code := httpmsg.Error(dErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+	req.Principal = principalFrom(r)
+	req.Client = h.clientFrom(r)
+
+	res, sErr := h.AuthSvc.StartPhoneChange(req)
+	if sErr != nil {
+		msg, code := httpmsg.Error(sErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
+
+func (h Handler) ConfirmPhoneChangeHandler(w http.ResponseWriter, r *http.Request) {
+	var req service.ConfirmPhoneChangeRequest
+	if dErr := json.NewDecoder(r.Body).Decode(&req); dErr != nil {
+		msg, code := httpmsg.Error(dErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+	req.Principal = principalFrom(r)
+	req.Client = h.clientFrom(r)
+
+	res, cErr := h.AuthSvc.ConfirmPhoneChange(req)
+	if cErr != nil {
+		if h.LoginRateLimiter.OnLimit(w, r, service.PhoneChangeAttemptsKey(req.Principal.Subject)) {
+			httpresponse.SetJsonContentType(w)
+			httpresponse.SetStatus(w, http.StatusTooManyRequests)
+			httpresponse.SetMessage(w, map[string]string{
+				"error": "Too many attempts",
+			})
+			return
+		}
+
+		msg, code := httpmsg.Error(cErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
@@ -0,0 +1,56 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hosseinasadian/chat-application/pkg/httpmsg"
+	"github.com/hosseinasadian/chat-application/pkg/httpresponse"
+)
+
+// ProofOfWorkHeader carries the solved puzzle, "<puzzle>:<nonce>".
+const ProofOfWorkHeader = "X-Proof-Of-Work"
+
+// ProofOfWorkMiddleware holds back requests to the routes configured for a
+// proof of work until they carry a solution. Without one the caller gets 428
+// and a puzzle to solve before repeating the request.
+func (h Handler) ProofOfWorkMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the pattern within this router, without the prefix it is mounted at
+		route := ""
+		if patterns := chi.RouteContext(r.Context()).RoutePatterns; len(patterns) > 0 {
+			route = patterns[len(patterns)-1]
+		}
+
+		if !h.AuthSvc.ProofOfWorkRequired(route) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		client := h.clientFrom(r)
+		vErr := h.AuthSvc.VerifyProofOfWork(route, client, r.Header.Get(ProofOfWorkHeader))
+		if vErr == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		challenge, cErr := h.AuthSvc.ProofOfWorkChallenge(route, client)
+		if cErr != nil {
+			msg, code := httpmsg.Error(cErr)
+			httpresponse.SetJsonContentType(w)
+			httpresponse.SetStatus(w, code)
+			httpresponse.SetMessage(w, map[string]string{
+				"error": msg,
+			})
+			return
+		}
+
+		msg, _ := httpmsg.Error(vErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, http.StatusPreconditionRequired)
+		httpresponse.SetMessage(w, map[string]any{
+			"error":     msg,
+			"challenge": challenge,
+		})
+	})
+}
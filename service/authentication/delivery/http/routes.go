@@ -11,18 +11,137 @@ func (h Handler) Routes() chi.Router {
 
 	r.Group(func(r chi.Router) {
 		r.Use(httprate.LimitByIP(10, time.Minute))
+		r.Use(h.ProofOfWorkMiddleware)
 
 		r.Post("/send-otp", h.SendOtpHandler)
 		r.Post("/verify-otp", h.VerifyOtpHandler)
+		r.Post("/verify-mfa", h.VerifyMFAHandler)
+		r.Post("/device-approval", h.CompleteDeviceApprovalHandler)
 		r.Post("/refresh-token", h.RefreshTokenHandler)
+
+		r.Post("/email/send-login", h.SendEmailLoginHandler)
+		r.Post("/email/verify-otp", h.VerifyEmailOtpHandler)
+		r.Post("/email/magic-link", h.VerifyMagicLinkHandler)
+
+		r.Post("/passkey/login/begin", h.BeginPasskeyLoginHandler)
+		r.Post("/passkey/login/finish", h.FinishPasskeyLoginHandler)
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(h.ClientAuthMiddleware)
+
+		r.Post("/introspect", h.IntrospectHandler)
+	})
+
+	r.Get("/.well-known/openid-configuration", h.DiscoveryHandler)
+
+	r.Route("/oauth", func(r chi.Router) {
+		r.Get("/authorize", h.AuthorizeHandler)
+		r.Post("/token", h.TokenHandler)
+		r.Get("/jwks", h.JWKSHandler)
+
+		r.Group(func(r chi.Router) {
+			r.Use(h.AuthMiddleware)
+
+			r.Get("/userinfo", h.UserInfoHandler)
+			r.Get("/consent/{requestID}", h.GetConsentHandler)
+			r.Post("/consent/{requestID}", h.DecideConsentHandler)
+		})
 	})
 
 	r.Route("/me", func(r chi.Router) {
 		r.Use(h.AuthMiddleware)
 
 		r.Get("/", h.MeHandler)
+		r.Delete("/", h.DeleteAccountHandler)
 		r.Post("/logout", h.LogoutHandler)
+
+		r.Post("/deletion/cancel", h.CancelAccountDeletionHandler)
+		r.Get("/security-events", h.ListSecurityEventsHandler)
+		r.Get("/notifications", h.NotificationsHandler)
+		r.Post("/reports", h.ReportUserHandler)
+
+		r.Route("/devices", func(r chi.Router) {
+			r.Get("/", h.ListDevicesHandler)
+			r.Post("/settings", h.UpdateDeviceSettingsHandler)
+			r.Post("/approvals/{approvalID}", h.DecideDeviceApprovalHandler)
+			r.Post("/{deviceID}", h.UpdateDeviceHandler)
+			r.Delete("/{deviceID}", h.RemoveDeviceHandler)
+		})
+
+		r.Route("/export", func(r chi.Router) {
+			r.Post("/", h.RequestAccountExportHandler)
+			r.Get("/", h.GetAccountExportHandler)
+			r.Get("/download", h.DownloadAccountExportHandler)
+		})
+
+		r.Route("/email", func(r chi.Router) {
+			r.Post("/", h.LinkEmailHandler)
+			r.Post("/verify", h.VerifyEmailHandler)
+			r.Delete("/", h.UnlinkEmailHandler)
+		})
+
+		r.Route("/phone", func(r chi.Router) {
+			r.Post("/change", h.StartPhoneChangeHandler)
+			r.Post("/change/confirm", h.ConfirmPhoneChangeHandler)
+		})
+
+		r.Route("/mfa/totp", func(r chi.Router) {
+			r.Post("/enroll", h.EnrollTOTPHandler)
+			r.Post("/confirm", h.ConfirmTOTPHandler)
+			r.Post("/disable", h.DisableTOTPHandler)
+		})
+
+		r.Route("/passkeys", func(r chi.Router) {
+			r.Get("/", h.ListPasskeysHandler)
+			r.Post("/register/begin", h.BeginPasskeyRegistrationHandler)
+			r.Post("/register/finish", h.FinishPasskeyRegistrationHandler)
+			r.Delete("/{passkeyID}", h.DeletePasskeyHandler)
+		})
+
+		r.Route("/oauth-clients", func(r chi.Router) {
+			r.Get("/", h.ListOAuthClientsHandler)
+			r.Post("/", h.CreateOAuthClientHandler)
+			r.Delete("/{clientID}", h.DeleteOAuthClientHandler)
+		})
+
+		r.Route("/bots", func(r chi.Router) {
+			r.Get("/", h.ListBotsHandler)
+			r.Post("/", h.CreateBotHandler)
+			r.Delete("/{botID}", h.DeleteBotHandler)
+
+			r.Get("/{botID}/keys", h.ListAPIKeysHandler)
+			r.Post("/{botID}/keys", h.CreateAPIKeyHandler)
+			r.Delete("/{botID}/keys/{keyID}", h.RevokeAPIKeyHandler)
+		})
+	})
+
+	return r
+}
+
+// Routes of the operator API, served by AdminHandler on its own port.
+func (h AdminHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Use(h.AuthMiddleware)
+
+	r.Route("/users", func(r chi.Router) {
+		r.Get("/", h.LookupUserHandler)
+		r.Get("/{userID}", h.LookupUserHandler)
+		r.Post("/{userID}/sessions/revoke", h.RevokeUserSessionsHandler)
+		r.Post("/{userID}/role", h.SetRoleHandler)
 	})
 
+	r.Get("/roles", h.ListRolesHandler)
+	r.Post("/lockouts/unlock", h.UnlockLoginHandler)
+
+	r.Route("/bans", func(r chi.Router) {
+		r.Get("/", h.ListBansHandler)
+		r.Post("/", h.BanPhoneHandler)
+		r.Delete("/{phone}", h.UnbanPhoneHandler)
+	})
+
+	r.Get("/reports", h.ListReportsHandler)
+	r.Get("/audit", h.QueryAuditLogHandler)
+
 	return r
 }
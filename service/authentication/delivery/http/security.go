@@ -0,0 +1,39 @@
+package http
+
+import (
+	"github.com/hosseinasadian/chat-application/pkg/httpmsg"
+	"github.com/hosseinasadian/chat-application/pkg/httpresponse"
+	"github.com/hosseinasadian/chat-application/service/authentication/service"
+	"net/http"
+	"strconv"
+)
+
+func (h Handler) ListSecurityEventsHandler(w http.ResponseWriter, r *http.Request) {
+	req := service.ListSecurityEventsRequest{Principal: principalFrom(r)}
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		limit, cErr := strconv.Atoi(raw)
+		if cErr != nil {
+			httpresponse.SetJsonContentType(w)
+			httpresponse.SetStatus(w, http.StatusBadRequest)
+			httpresponse.SetMessage(w, map[string]string{
+				"error": "limit must be a number",
+			})
+			return
+		}
+		req.Limit = limit
+	}
+
+	res, lErr := h.AuthSvc.ListSecurityEvents(req)
+	if lErr != nil {
+		msg, code := httpmsg.Error(lErr)
+		httpresponse.SetJsonContentType(w)
+		httpresponse.SetStatus(w, code)
+		httpresponse.SetMessage(w, map[string]string{
+			"error": msg,
+		})
+		return
+	}
+
+	httpresponse.SetJsonContentType(w)
+	httpresponse.SetMessage(w, res)
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/go-chi/httprate"
+	"github.com/hosseinasadian/chat-application/adapter/redis"
+)
+
+// LoginAttempts keeps the counters of the login rate limiter in Redis, so
+// every instance sees the same lockouts and operators can lift them. The
+// windows of a key share the hash LoginAttemptsKey(key).
+type LoginAttempts struct {
+	adapter      redis.Adapter
+	windowLength time.Duration
+}
+
+var _ httprate.LimitCounter = (*LoginAttempts)(nil)
+
+func NewLoginAttempts(adapter redis.Adapter) *LoginAttempts {
+	return &LoginAttempts{adapter: adapter}
+}
+
+func LoginAttemptsKey(key string) string {
+	return "login-attempts:" + key
+}
+
+func (repo *LoginAttempts) Config(requestLimit int, windowLength time.Duration) {
+	repo.windowLength = windowLength
+}
+
+func (repo *LoginAttempts) Increment(key string, currentWindow time.Time) error {
+	return repo.IncrementBy(key, currentWindow, 1)
+}
+
+func (repo *LoginAttempts) IncrementBy(key string, currentWindow time.Time, amount int) error {
+	client, ctx := repo.adapter.Client(), repo.adapter.Context()
+
+	// the previous window is still read, older ones are not
+	pipe := client.TxPipeline()
+	pipe.HIncrBy(ctx, LoginAttemptsKey(key), windowField(currentWindow), int64(amount))
+	pipe.Expire(ctx, LoginAttemptsKey(key), 2*repo.windowLength)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (repo *LoginAttempts) Get(key string, currentWindow, previousWindow time.Time) (int, int, error) {
+	client, ctx := repo.adapter.Client(), repo.adapter.Context()
+
+	values, err := client.HMGet(ctx, LoginAttemptsKey(key), windowField(currentWindow), windowField(previousWindow)).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return countOf(values, 0), countOf(values, 1), nil
+}
+
+func windowField(window time.Time) string {
+	return strconv.FormatInt(window.Unix(), 10)
+}
+
+func countOf(values []any, i int) int {
+	if i >= len(values) {
+		return 0
+	}
+
+	raw, _ := values[i].(string)
+	count, _ := strconv.Atoi(raw)
+	return count
+}
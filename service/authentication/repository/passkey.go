@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/hosseinasadian/chat-application/adapter/redis"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+var ErrNotFound = errors.New("not found")
+
+type PasskeyCredential struct {
+	Credential webauthn.Credential `json:"credential"`
+	Name       string              `json:"name"`
+	CreatedAt  time.Time           `json:"created_at"`
+	LastUsedAt time.Time           `json:"last_used_at,omitempty"`
+}
+
+// Passkey keeps WebAuthn credentials in Redis. Each user gets a random user
+// handle so authenticators never store the user id itself.
+type Passkey struct {
+	adapter redis.Adapter
+}
+
+func NewPasskey(adapter redis.Adapter) Passkey {
+	return Passkey{adapter: adapter}
+}
+
+func (repo Passkey) UserHandle(userID string) ([]byte, error) {
+	client, ctx := repo.adapter.Client(), repo.adapter.Context()
+
+	encoded, err := client.Get(ctx, "passkey-handle:"+userID).Result()
+	if err == nil {
+		return base64.RawURLEncoding.DecodeString(encoded)
+	} else if !errors.Is(err, goredis.Nil) {
+		return nil, err
+	}
+
+	handle := make([]byte, 32)
+	if _, rErr := rand.Read(handle); rErr != nil {
+		return nil, rErr
+	}
+	encoded = base64.RawURLEncoding.EncodeToString(handle)
+
+	created, err := client.SetNX(ctx, "passkey-handle:"+userID, encoded, 0).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		// another request assigned the handle first
+		return repo.UserHandle(userID)
+	}
+
+	return handle, client.Set(ctx, "passkey-owner:"+encoded, userID, 0).Err()
+}
+
+func (repo Passkey) UserIDByHandle(handle []byte) (string, error) {
+	client, ctx := repo.adapter.Client(), repo.adapter.Context()
+
+	userID, err := client.Get(ctx, "passkey-owner:"+base64.RawURLEncoding.EncodeToString(handle)).Result()
+	if errors.Is(err, goredis.Nil) {
+		return "", ErrNotFound
+	}
+
+	return userID, err
+}
+
+func (repo Passkey) Credentials(userID string) ([]PasskeyCredential, error) {
+	client, ctx := repo.adapter.Client(), repo.adapter.Context()
+
+	values, err := client.HGetAll(ctx, "passkey-credentials:"+userID).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := make([]PasskeyCredential, 0, len(values))
+	for _, value := range values {
+		var credential PasskeyCredential
+		if uErr := json.Unmarshal([]byte(value), &credential); uErr != nil {
+			return nil, uErr
+		}
+		credentials = append(credentials, credential)
+	}
+
+	return credentials, nil
+}
+
+func (repo Passkey) SaveCredential(userID string, credential PasskeyCredential) error {
+	client, ctx := repo.adapter.Client(), repo.adapter.Context()
+
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return err
+	}
+
+	field := base64.RawURLEncoding.EncodeToString(credential.Credential.ID)
+	return client.HSet(ctx, "passkey-credentials:"+userID, field, data).Err()
+}
+
+func (repo Passkey) DeleteCredential(userID string, credentialID []byte) error {
+	client, ctx := repo.adapter.Client(), repo.adapter.Context()
+
+	field := base64.RawURLEncoding.EncodeToString(credentialID)
+	deleted, err := client.HDel(ctx, "passkey-credentials:"+userID, field).Result()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (repo Passkey) SaveSession(key string, session webauthn.SessionData, ttl time.Duration) error {
+	client, ctx := repo.adapter.Client(), repo.adapter.Context()
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	return client.Set(ctx, "passkey-session:"+key, data, ttl).Err()
+}
+
+// TakeSession returns the ceremony stored under key and removes it, so every
+// ceremony can be finished once.
+func (repo Passkey) TakeSession(key string) (webauthn.SessionData, error) {
+	client, ctx := repo.adapter.Client(), repo.adapter.Context()
+
+	data, err := client.GetDel(ctx, "passkey-session:"+key).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return webauthn.SessionData{}, ErrNotFound
+	} else if err != nil {
+		return webauthn.SessionData{}, err
+	}
+
+	var session webauthn.SessionData
+	err = json.Unmarshal(data, &session)
+	return session, err
+}
+
+// DeleteAll removes every passkey of the user together with the user handle.
+func (repo Passkey) DeleteAll(userID string) error {
+	client, ctx := repo.adapter.Client(), repo.adapter.Context()
+
+	encoded, err := client.Get(ctx, "passkey-handle:"+userID).Result()
+	if err != nil && !errors.Is(err, goredis.Nil) {
+		return err
+	}
+
+	keys := []string{"passkey-handle:" + userID, "passkey-credentials:" + userID, "passkey-session:register:" + userID}
+	if encoded != "" {
+		keys = append(keys, "passkey-owner:"+encoded)
+	}
+
+	return client.Del(ctx, keys...).Err()
+}
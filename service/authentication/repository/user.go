@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hosseinasadian/chat-application/adapter/redis"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// UserIdentity ties the opaque id that tokens carry to the phone number the
+// user currently logs in with.
+type UserIdentity struct {
+	ID             string    `json:"id"`
+	Phone          string    `json:"phone"`
+	CreatedAt      time.Time `json:"created_at"`
+	PhoneChangedAt time.Time `json:"phone_changed_at,omitempty"`
+}
+
+var ErrPhoneTaken = errors.New("phone number belongs to another user")
+
+// User assigns every phone number a stable id the first time it logs in.
+// Until the user service owns accounts the mapping lives in Redis next to the
+// rest of the authentication data.
+type User struct {
+	adapter redis.Adapter
+}
+
+func NewUser(adapter redis.Adapter) User {
+	return User{adapter: adapter}
+}
+
+// FindOrCreate returns the identity of phone, creating it on first use.
+func (repo User) FindOrCreate(phone string) (UserIdentity, error) {
+	client, ctx := repo.adapter.Client(), repo.adapter.Context()
+
+	id, err := client.Get(ctx, "user-id:"+phone).Result()
+	if err == nil {
+		return repo.ByID(id)
+	} else if !errors.Is(err, goredis.Nil) {
+		return UserIdentity{}, err
+	}
+
+	newID, err := uuid.NewV7()
+	if err != nil {
+		return UserIdentity{}, err
+	}
+
+	identity := UserIdentity{ID: newID.String(), Phone: phone, CreatedAt: time.Now().UTC()}
+	data, err := json.Marshal(identity)
+	if err != nil {
+		return UserIdentity{}, err
+	}
+
+	// the record is written first so a claimed phone never points at nothing
+	if sErr := client.Set(ctx, "user:"+identity.ID, data, 0).Err(); sErr != nil {
+		return UserIdentity{}, sErr
+	}
+
+	created, err := client.SetNX(ctx, "user-id:"+phone, identity.ID, 0).Result()
+	if err != nil {
+		return UserIdentity{}, err
+	}
+	if !created {
+		// another request assigned the id first
+		_ = client.Del(ctx, "user:"+identity.ID).Err()
+		return repo.FindOrCreate(phone)
+	}
+
+	return identity, nil
+}
+
+func (repo User) ByID(id string) (UserIdentity, error) {
+	client, ctx := repo.adapter.Client(), repo.adapter.Context()
+
+	data, err := client.Get(ctx, "user:"+id).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return UserIdentity{}, ErrNotFound
+	} else if err != nil {
+		return UserIdentity{}, err
+	}
+
+	var identity UserIdentity
+	err = json.Unmarshal(data, &identity)
+	return identity, err
+}
+
+func (repo User) IDByPhone(phone string) (string, error) {
+	client, ctx := repo.adapter.Client(), repo.adapter.Context()
+
+	id, err := client.Get(ctx, "user-id:"+phone).Result()
+	if errors.Is(err, goredis.Nil) {
+		return "", ErrNotFound
+	}
+
+	return id, err
+}
+
+// ChangePhone moves the user to newPhone in one transaction. The old number
+// is released and can be used to sign up again.
+func (repo User) ChangePhone(id, newPhone string) (UserIdentity, error) {
+	client, ctx := repo.adapter.Client(), repo.adapter.Context()
+
+	var identity UserIdentity
+	err := client.Watch(ctx, func(tx *goredis.Tx) error {
+		owner, err := tx.Get(ctx, "user-id:"+newPhone).Result()
+		if err == nil && owner != id {
+			return ErrPhoneTaken
+		} else if err != nil && !errors.Is(err, goredis.Nil) {
+			return err
+		}
+
+		data, err := tx.Get(ctx, "user:"+id).Bytes()
+		if errors.Is(err, goredis.Nil) {
+			return ErrNotFound
+		} else if err != nil {
+			return err
+		}
+		if uErr := json.Unmarshal(data, &identity); uErr != nil {
+			return uErr
+		}
+
+		oldPhone := identity.Phone
+		identity.Phone = newPhone
+		identity.PhoneChangedAt = time.Now().UTC()
+		updated, err := json.Marshal(identity)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+			pipe.Set(ctx, "user:"+id, updated, 0)
+			pipe.Set(ctx, "user-id:"+newPhone, id, 0)
+			pipe.Del(ctx, "user-id:"+oldPhone)
+			return nil
+		})
+		return err
+	}, "user-id:"+newPhone, "user:"+id)
+
+	return identity, err
+}
+
+// Delete removes the user and frees the phone number.
+func (repo User) Delete(id string) error {
+	client, ctx := repo.adapter.Client(), repo.adapter.Context()
+
+	identity, err := repo.ByID(id)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	pipe := client.TxPipeline()
+	pipe.Del(ctx, "user:"+id)
+	// the number may already belong to someone who signed up with it again
+	if owner, gErr := client.Get(ctx, "user-id:"+identity.Phone).Result(); gErr == nil && owner == id {
+		pipe.Del(ctx, "user-id:"+identity.Phone)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/hosseinasadian/chat-application/pkg/richerror"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	AccountEventPhoneChanged = "phone_changed"
+	AccountEventDeleted      = "account_deleted"
+)
+
+const maxPurgeBackoff = 6 * time.Hour
+
+type AccountConfig struct {
+	DeletionGracePeriod time.Duration `koanf:"deletion_grace_period"`
+	ExportDir           string        `koanf:"export_dir"`
+	ExportTTL           time.Duration `koanf:"export_ttl"`
+	ExportDownloadURL   string        `koanf:"export_download_url"`
+	JobInterval         time.Duration `koanf:"job_interval"`
+}
+
+// AccountEvent tells other services that an account changed. They are
+// appended to the "account-events" stream as JSON in the "event" field and
+// never trimmed, so a consumer group can replay what it missed. The user
+// service fans phone changes out to contacts when the user allowed it, and
+// on account_deleted it removes the profile and memberships and anonymizes
+// messages. Delivery is at least once, a purge that is retried can add
+// account_deleted again.
+type AccountEvent struct {
+	Type           string    `json:"type"`
+	UserID         string    `json:"user_id"`
+	Phone          string    `json:"phone,omitempty"`
+	NotifyContacts bool      `json:"notify_contacts"`
+	OccurredAt     time.Time `json:"occurred_at"`
+}
+
+// DeleteAccount schedules the account for deletion once the grace period
+// ends. The user can keep logging in until then and cancel at any time.
+func (s Service) DeleteAccount(req DeleteAccountRequest) (DeleteAccountResponse, error) {
+	const op = "authentication.service.DeleteAccount"
+
+	if !req.Principal.IsUser() {
+		return DeleteAccountResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Only users can delete their account")
+	}
+
+	purgeAt := time.Now().Add(s.config.Account.DeletionGracePeriod).UTC().Truncate(time.Second)
+
+	// asking twice keeps the first date
+	redisAdapter := s.otpRepo.Adapter()
+	client, ctx := redisAdapter.Client(), redisAdapter.Context()
+	if err := client.ZAddNX(ctx, "account-deletions", redis.Z{Score: float64(purgeAt.Unix()), Member: req.Principal.Subject}).Err(); err != nil {
+		return DeleteAccountResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	scheduled, err := s.pendingDeletion(req.Principal.Subject)
+	if err != nil {
+		return DeleteAccountResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	return DeleteAccountResponse{Message: "Account scheduled for deletion", DeletionScheduledFor: scheduled}, nil
+}
+
+func (s Service) CancelAccountDeletion(req CancelAccountDeletionRequest) (CancelAccountDeletionResponse, error) {
+	const op = "authentication.service.CancelAccountDeletion"
+
+	if !req.Principal.IsUser() {
+		return CancelAccountDeletionResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Only users can delete their account")
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	removed, err := redisAdapter.Client().ZRem(redisAdapter.Context(), "account-deletions", req.Principal.Subject).Result()
+	if err != nil {
+		return CancelAccountDeletionResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+	_ = redisAdapter.Client().HDel(redisAdapter.Context(), "account-deletion-failures", req.Principal.Subject).Err()
+	if removed == 0 {
+		return CancelAccountDeletionResponse{}, richerror.New(op).WithKind(richerror.KindNotFound).WithMessage("Account is not scheduled for deletion")
+	}
+
+	return CancelAccountDeletionResponse{Message: "Account deletion cancelled"}, nil
+}
+
+// PurgeDeletedAccounts deletes every account whose grace period has ended
+// and reports how many were deleted.
+func (s Service) PurgeDeletedAccounts() (int, error) {
+	redisAdapter := s.otpRepo.Adapter()
+	client, ctx := redisAdapter.Client(), redisAdapter.Context()
+
+	due, err := client.ZRangeByScore(ctx, "account-deletions", &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(time.Now().Unix(), 10),
+	}).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, userID := range due {
+		// removing the entry claims the account, another instance running
+		// the same job skips it
+		removed, rErr := client.ZRem(ctx, "account-deletions", userID).Result()
+		if rErr != nil {
+			return purged, rErr
+		}
+		if removed == 0 {
+			continue
+		}
+
+		if pErr := s.purgeAccount(userID); pErr != nil {
+			// put it back for a later run, the others are still purged
+			retryAt, bErr := s.purgeRetryAt(userID)
+			if bErr == nil {
+				bErr = client.ZAdd(ctx, "account-deletions", redis.Z{Score: float64(retryAt.Unix()), Member: userID}).Err()
+			}
+			if bErr != nil {
+				log.Printf("account purge of %s failed and was not rescheduled: %v, %v\n", userID, pErr, bErr)
+				continue
+			}
+			log.Printf("account purge of %s failed, retrying at %s: %v\n", userID, retryAt.Format(time.RFC3339), pErr)
+			continue
+		}
+		_ = client.HDel(ctx, "account-deletion-failures", userID).Err()
+		purged++
+	}
+
+	return purged, nil
+}
+
+// purgeRetryAt counts a failed purge of the account and returns when to try
+// again, backing off exponentially from the job interval up to
+// maxPurgeBackoff.
+func (s Service) purgeRetryAt(userID string) (time.Time, error) {
+	redisAdapter := s.otpRepo.Adapter()
+	failures, err := redisAdapter.Client().HIncrBy(redisAdapter.Context(), "account-deletion-failures", userID, 1).Result()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	backoff := maxPurgeBackoff
+	if shift := failures - 1; shift < 20 {
+		backoff = min(s.config.Account.JobInterval<<shift, maxPurgeBackoff)
+	}
+
+	return time.Now().Add(backoff), nil
+}
+
+// purgeAccount removes everything the authentication service holds about
+// the user. Other services clean up their own data on account_deleted.
+func (s Service) purgeAccount(userID string) error {
+	identity, err := s.userRepo.ByID(userID)
+	if err != nil {
+		return err
+	}
+
+	principal := Principal{Kind: PrincipalUser, Subject: userID, Phone: identity.Phone}
+
+	bots, err := s.ListBots(ListBotsRequest{Principal: principal})
+	if err != nil {
+		return err
+	}
+	for _, bot := range bots.Bots {
+		if _, dErr := s.DeleteBot(DeleteBotRequest{Principal: principal, BotID: bot.ID}); dErr != nil {
+			return dErr
+		}
+	}
+
+	clients, err := s.ListOAuthClients(ListOAuthClientsRequest{Principal: principal})
+	if err != nil {
+		return err
+	}
+	for _, client := range clients.Clients {
+		if _, dErr := s.DeleteOAuthClient(DeleteOAuthClientRequest{Principal: principal, ClientID: client.ID}); dErr != nil {
+			return dErr
+		}
+	}
+
+	if dErr := s.passkeyRepo.DeleteAll(userID); dErr != nil {
+		return dErr
+	}
+
+	s.revokeAllFamilies(SecurityEventAccountDeleted, userID, identity.Phone)
+
+	redisAdapter := s.otpRepo.Adapter()
+	client, ctx := redisAdapter.Client(), redisAdapter.Context()
+	if address, eErr := s.emailOf(userID); eErr == nil {
+		if dErr := client.Del(ctx, "email:"+address, "email-login:"+address).Err(); dErr != nil {
+			return dErr
+		}
+	}
+	if sErr := s.scanKeys("oauth-consent:"+userID+":", func(key string) error {
+		return client.Del(ctx, key).Err()
+	}); sErr != nil {
+		return sErr
+	}
+	if dErr := client.Del(ctx,
+		"totp:"+userID,
+		"totp-step:"+userID,
+		"totp-recovery-used:"+userID,
+		"totp-disable:"+userID+":attempts",
+		"user-email:"+userID,
+		"security-events:"+userID,
+		"email-verify:"+userID,
+		"phone-change:"+userID,
+		"account-export:"+userID,
+		"devices:"+userID,
+		"device-settings:"+userID,
+		"device-secrets:"+userID,
+	).Err(); dErr != nil {
+		return dErr
+	}
+	if dErr := client.HDel(ctx, "admin-roles", userID).Err(); dErr != nil {
+		return dErr
+	}
+	s.removeExportArchive(userID)
+
+	// recorded before the user is gone, a failure leaves the account to be
+	// purged again by a later run
+	if pErr := s.publishAccountEvent(AccountEvent{Type: AccountEventDeleted, UserID: userID}); pErr != nil {
+		return pErr
+	}
+
+	return s.userRepo.Delete(userID)
+}
+
+// pendingDeletion returns when the account will be deleted, nil when it is
+// not scheduled.
+func (s Service) pendingDeletion(userID string) (*time.Time, error) {
+	redisAdapter := s.otpRepo.Adapter()
+	score, err := redisAdapter.Client().ZScore(redisAdapter.Context(), "account-deletions", userID).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	purgeAt := time.Unix(int64(score), 0).UTC()
+	return &purgeAt, nil
+}
+
+func (s Service) publishAccountEvent(event AccountEvent) error {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now().UTC()
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	return redisAdapter.Client().XAdd(redisAdapter.Context(), &redis.XAddArgs{
+		Stream: "account-events",
+		Values: map[string]any{"event": data},
+	}).Err()
+}
+
+// RunJobs purges accounts at the end of their grace period and builds the
+// requested exports, until ctx is done.
+func (s Service) RunJobs(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Account.JobInterval)
+	defer ticker.Stop()
+
+	for {
+		if purged, err := s.PurgeDeletedAccounts(); err != nil {
+			log.Printf("account purge failed: %v\n", err)
+		} else if purged > 0 {
+			log.Printf("purged %d deleted accounts\n", purged)
+		}
+
+		if err := s.buildPendingExports(); err != nil {
+			log.Printf("account export failed: %v\n", err)
+		}
+		s.removeExpiredExports()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
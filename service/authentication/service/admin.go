@@ -0,0 +1,508 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/hosseinasadian/chat-application/pkg/richerror"
+	"github.com/hosseinasadian/chat-application/service/authentication/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+// Roles of operators, each allowing everything the one before it does.
+const (
+	RoleSupport   = "support"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+const (
+	PermissionUsersRead      = "users:read"
+	PermissionReportsRead    = "reports:read"
+	PermissionAuditRead      = "audit:read"
+	PermissionSessionsRevoke = "sessions:revoke"
+	PermissionLockoutsLift   = "lockouts:lift"
+	PermissionPhonesBan      = "phones:ban"
+	PermissionRolesManage    = "roles:manage"
+)
+
+var AdminRoles = []string{RoleSupport, RoleModerator, RoleAdmin}
+
+var rolePermissions = map[string][]string{
+	RoleSupport: {PermissionUsersRead, PermissionReportsRead, PermissionAuditRead},
+	RoleModerator: {PermissionUsersRead, PermissionReportsRead, PermissionAuditRead,
+		PermissionSessionsRevoke, PermissionLockoutsLift, PermissionPhonesBan},
+	RoleAdmin: {PermissionUsersRead, PermissionReportsRead, PermissionAuditRead,
+		PermissionSessionsRevoke, PermissionLockoutsLift, PermissionPhonesBan, PermissionRolesManage},
+}
+
+// AdminUser is what operators see of an account.
+type AdminUser struct {
+	ID                   string       `json:"id"`
+	Phone                string       `json:"phone"`
+	Email                string       `json:"email,omitempty"`
+	Role                 string       `json:"role,omitempty"`
+	CreatedAt            time.Time    `json:"created_at"`
+	PhoneChangedAt       time.Time    `json:"phone_changed_at,omitempty"`
+	MFAEnabled           bool         `json:"mfa_enabled"`
+	Passkeys             int          `json:"passkeys"`
+	Devices              []DeviceInfo `json:"devices"`
+	Ban                  *PhoneBan    `json:"ban,omitempty"`
+	DeletionScheduledFor *time.Time   `json:"deletion_scheduled_for,omitempty"`
+}
+
+// PhoneBan keeps a phone number from logging in and signing up.
+type PhoneBan struct {
+	Phone    string    `json:"phone"`
+	Reason   string    `json:"reason,omitempty"`
+	BannedBy string    `json:"banned_by"`
+	BannedAt time.Time `json:"banned_at"`
+}
+
+type AdminRole struct {
+	UserID string `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+// authorizeAdmin checks that principal holds permission and returns the actor
+// recorded in the audit log. Operators at the command line already have
+// access to the stores and may do everything.
+func (s Service) authorizeAdmin(op richerror.Operation, principal Principal, permission string) (string, error) {
+	switch principal.Kind {
+	case PrincipalOperator:
+		return "operator:" + principal.Subject, nil
+	case PrincipalUser:
+		role, err := s.adminRole(principal.Subject)
+		if err != nil {
+			return "", richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+		}
+		if slices.Contains(rolePermissions[role], permission) {
+			return principal.Subject, nil
+		}
+	}
+
+	return "", richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Missing permission " + permission)
+}
+
+// auditAdmin writes the action of an operator to the audit log. Changes are
+// logged before they are made and reads before anything is returned, so
+// neither happens when the log cannot be written.
+func (s Service) auditAdmin(op richerror.Operation, event SecurityEvent) error {
+	if err := s.emitSecurityEvent(event); err != nil {
+		return richerror.New(op).WithKind(richerror.KindUnexpected).WithMessage("Audit log is unavailable").WithWrapper(err)
+	}
+
+	return nil
+}
+
+func (s Service) LookupUser(req AdminLookupUserRequest) (AdminLookupUserResponse, error) {
+	const op = "authentication.service.LookupUser"
+
+	actor, err := s.authorizeAdmin(op, req.Principal, PermissionUsersRead)
+	if err != nil {
+		return AdminLookupUserResponse{}, err
+	}
+
+	if req.Phone != "" {
+		req.Phone = s.normalizePhone(req.Phone)
+	}
+	if vErr := s.validator.validateAdminLookupUser(req); vErr != nil {
+		return AdminLookupUserResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage(vErr.Error())
+	}
+
+	identity, err := s.adminIdentity(req.UserID, req.Phone)
+	if errors.Is(err, repository.ErrNotFound) {
+		return AdminLookupUserResponse{}, richerror.New(op).WithKind(richerror.KindNotFound).WithMessage("User not found")
+	} else if err != nil {
+		return AdminLookupUserResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	user, err := s.adminUser(identity)
+	if err != nil {
+		return AdminLookupUserResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	if aErr := s.auditAdmin(op, SecurityEvent{
+		Type:    SecurityEventAdminUserViewed,
+		ActorID: actor,
+		UserID:  identity.ID,
+		Phone:   identity.Phone,
+	}); aErr != nil {
+		return AdminLookupUserResponse{}, aErr
+	}
+
+	return AdminLookupUserResponse{User: user}, nil
+}
+
+// RevokeUserSessions logs the user out on every device.
+func (s Service) RevokeUserSessions(req AdminRevokeSessionsRequest) (AdminRevokeSessionsResponse, error) {
+	const op = "authentication.service.RevokeUserSessions"
+
+	actor, err := s.authorizeAdmin(op, req.Principal, PermissionSessionsRevoke)
+	if err != nil {
+		return AdminRevokeSessionsResponse{}, err
+	}
+
+	identity, err := s.userRepo.ByID(req.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return AdminRevokeSessionsResponse{}, richerror.New(op).WithKind(richerror.KindNotFound).WithMessage("User not found")
+	} else if err != nil {
+		return AdminRevokeSessionsResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	if aErr := s.auditAdmin(op, SecurityEvent{
+		Type:    SecurityEventAdminSessionsRevoked,
+		ActorID: actor,
+		UserID:  identity.ID,
+		Phone:   identity.Phone,
+	}); aErr != nil {
+		return AdminRevokeSessionsResponse{}, aErr
+	}
+	s.revokeAllFamilies(SecurityEventAdminSessionsRevoked, identity.ID, identity.Phone)
+
+	return AdminRevokeSessionsResponse{Message: "Sessions revoked"}, nil
+}
+
+// UnlockLogin lifts the lockouts a phone number and its account ran into by
+// failing codes too often.
+func (s Service) UnlockLogin(req AdminUnlockLoginRequest) (AdminUnlockLoginResponse, error) {
+	const op = "authentication.service.UnlockLogin"
+
+	actor, err := s.authorizeAdmin(op, req.Principal, PermissionLockoutsLift)
+	if err != nil {
+		return AdminUnlockLoginResponse{}, err
+	}
+
+	req.Phone = s.normalizePhone(req.Phone)
+	if vErr := s.validator.validateAdminUnlockLogin(req); vErr != nil {
+		return AdminUnlockLoginResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage(vErr.Error())
+	}
+
+	keys := []string{repository.LoginAttemptsKey(s.OtpAttemptsKey(req.Phone))}
+	userID := s.existingUserID(req.Phone)
+	if userID != "" {
+		keys = append(keys,
+			repository.LoginAttemptsKey(PhoneChangeAttemptsKey(userID)),
+			repository.LoginAttemptsKey(EmailVerifyAttemptsKey(userID)),
+		)
+		if address, eErr := s.emailOf(userID); eErr == nil {
+			keys = append(keys, repository.LoginAttemptsKey(s.EmailOtpAttemptsKey(address)))
+		}
+	}
+
+	if aErr := s.auditAdmin(op, SecurityEvent{
+		Type:    SecurityEventAdminLoginUnlocked,
+		ActorID: actor,
+		UserID:  userID,
+		Phone:   req.Phone,
+	}); aErr != nil {
+		return AdminUnlockLoginResponse{}, aErr
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	if dErr := redisAdapter.Client().Del(redisAdapter.Context(), keys...).Err(); dErr != nil {
+		return AdminUnlockLoginResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(dErr)
+	}
+
+	return AdminUnlockLoginResponse{Message: "Login unlocked"}, nil
+}
+
+// BanPhone keeps the number from logging in and ends the sessions of its
+// account.
+func (s Service) BanPhone(req AdminBanPhoneRequest) (AdminBanPhoneResponse, error) {
+	const op = "authentication.service.BanPhone"
+
+	actor, err := s.authorizeAdmin(op, req.Principal, PermissionPhonesBan)
+	if err != nil {
+		return AdminBanPhoneResponse{}, err
+	}
+
+	req.Phone = s.normalizePhone(req.Phone)
+	if vErr := s.validator.validateAdminBanPhone(req); vErr != nil {
+		return AdminBanPhoneResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage(vErr.Error())
+	}
+
+	ban := PhoneBan{Phone: req.Phone, Reason: req.Reason, BannedBy: actor, BannedAt: time.Now().UTC()}
+	data, err := json.Marshal(ban)
+	if err != nil {
+		return AdminBanPhoneResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	userID := s.existingUserID(req.Phone)
+	if aErr := s.auditAdmin(op, SecurityEvent{
+		Type:    SecurityEventAdminPhoneBanned,
+		ActorID: actor,
+		UserID:  userID,
+		Phone:   req.Phone,
+		Reason:  req.Reason,
+	}); aErr != nil {
+		return AdminBanPhoneResponse{}, aErr
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	if hErr := redisAdapter.Client().HSet(redisAdapter.Context(), "phone-bans", req.Phone, data).Err(); hErr != nil {
+		return AdminBanPhoneResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(hErr)
+	}
+
+	s.revokeAllFamilies(SecurityEventAdminPhoneBanned, userID, req.Phone)
+
+	return AdminBanPhoneResponse{Ban: ban}, nil
+}
+
+func (s Service) UnbanPhone(req AdminUnbanPhoneRequest) (AdminUnbanPhoneResponse, error) {
+	const op = "authentication.service.UnbanPhone"
+
+	actor, err := s.authorizeAdmin(op, req.Principal, PermissionPhonesBan)
+	if err != nil {
+		return AdminUnbanPhoneResponse{}, err
+	}
+
+	req.Phone = s.normalizePhone(req.Phone)
+
+	redisAdapter := s.otpRepo.Adapter()
+	client, ctx := redisAdapter.Client(), redisAdapter.Context()
+	banned, err := client.HExists(ctx, "phone-bans", req.Phone).Result()
+	if err != nil {
+		return AdminUnbanPhoneResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+	if !banned {
+		return AdminUnbanPhoneResponse{}, richerror.New(op).WithKind(richerror.KindNotFound).WithMessage("Phone number is not banned")
+	}
+
+	if aErr := s.auditAdmin(op, SecurityEvent{
+		Type:    SecurityEventAdminPhoneUnbanned,
+		ActorID: actor,
+		UserID:  s.existingUserID(req.Phone),
+		Phone:   req.Phone,
+	}); aErr != nil {
+		return AdminUnbanPhoneResponse{}, aErr
+	}
+
+	if dErr := client.HDel(ctx, "phone-bans", req.Phone).Err(); dErr != nil {
+		return AdminUnbanPhoneResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(dErr)
+	}
+
+	return AdminUnbanPhoneResponse{Message: "Phone number unbanned"}, nil
+}
+
+func (s Service) ListPhoneBans(req AdminListPhoneBansRequest) (AdminListPhoneBansResponse, error) {
+	const op = "authentication.service.ListPhoneBans"
+
+	actor, err := s.authorizeAdmin(op, req.Principal, PermissionUsersRead)
+	if err != nil {
+		return AdminListPhoneBansResponse{}, err
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	stored, err := redisAdapter.Client().HGetAll(redisAdapter.Context(), "phone-bans").Result()
+	if err != nil {
+		return AdminListPhoneBansResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	bans := make([]PhoneBan, 0, len(stored))
+	for _, data := range stored {
+		var ban PhoneBan
+		if json.Unmarshal([]byte(data), &ban) == nil {
+			bans = append(bans, ban)
+		}
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].BannedAt.After(bans[j].BannedAt) })
+
+	if aErr := s.auditAdmin(op, SecurityEvent{Type: SecurityEventAdminBansViewed, ActorID: actor}); aErr != nil {
+		return AdminListPhoneBansResponse{}, aErr
+	}
+
+	return AdminListPhoneBansResponse{Bans: bans}, nil
+}
+
+// SetAdminRole gives the user a role, an empty role takes it away.
+func (s Service) SetAdminRole(req AdminSetRoleRequest) (AdminSetRoleResponse, error) {
+	const op = "authentication.service.SetAdminRole"
+
+	actor, err := s.authorizeAdmin(op, req.Principal, PermissionRolesManage)
+	if err != nil {
+		return AdminSetRoleResponse{}, err
+	}
+
+	if vErr := s.validator.validateAdminSetRole(req); vErr != nil {
+		return AdminSetRoleResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage(vErr.Error())
+	}
+
+	// an admin taking away their own role could leave nobody to manage roles
+	if req.Principal.IsUser() && req.Principal.Subject == req.UserID {
+		return AdminSetRoleResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("You cannot change your own role")
+	}
+
+	if _, uErr := s.userRepo.ByID(req.UserID); errors.Is(uErr, repository.ErrNotFound) {
+		return AdminSetRoleResponse{}, richerror.New(op).WithKind(richerror.KindNotFound).WithMessage("User not found")
+	} else if uErr != nil {
+		return AdminSetRoleResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(uErr)
+	}
+
+	if aErr := s.auditAdmin(op, SecurityEvent{
+		Type:    SecurityEventAdminRoleChanged,
+		ActorID: actor,
+		UserID:  req.UserID,
+		Reason:  req.Role,
+	}); aErr != nil {
+		return AdminSetRoleResponse{}, aErr
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	client, ctx := redisAdapter.Client(), redisAdapter.Context()
+	if req.Role == "" {
+		err = client.HDel(ctx, "admin-roles", req.UserID).Err()
+	} else {
+		err = client.HSet(ctx, "admin-roles", req.UserID, req.Role).Err()
+	}
+	if err != nil {
+		return AdminSetRoleResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	return AdminSetRoleResponse{Role: AdminRole{UserID: req.UserID, Role: req.Role}}, nil
+}
+
+func (s Service) ListAdminRoles(req AdminListRolesRequest) (AdminListRolesResponse, error) {
+	const op = "authentication.service.ListAdminRoles"
+
+	actor, err := s.authorizeAdmin(op, req.Principal, PermissionRolesManage)
+	if err != nil {
+		return AdminListRolesResponse{}, err
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	stored, err := redisAdapter.Client().HGetAll(redisAdapter.Context(), "admin-roles").Result()
+	if err != nil {
+		return AdminListRolesResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	roles := make([]AdminRole, 0, len(stored))
+	for userID, role := range stored {
+		roles = append(roles, AdminRole{UserID: userID, Role: role})
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].UserID < roles[j].UserID })
+
+	if aErr := s.auditAdmin(op, SecurityEvent{Type: SecurityEventAdminRolesViewed, ActorID: actor}); aErr != nil {
+		return AdminListRolesResponse{}, aErr
+	}
+
+	return AdminListRolesResponse{Roles: roles}, nil
+}
+
+func (s Service) adminRole(userID string) (string, error) {
+	redisAdapter := s.otpRepo.Adapter()
+	role, err := redisAdapter.Client().HGet(redisAdapter.Context(), "admin-roles", userID).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+
+	return role, err
+}
+
+func (s Service) adminIdentity(userID, phone string) (repository.UserIdentity, error) {
+	if userID == "" {
+		id, err := s.userRepo.IDByPhone(phone)
+		if err != nil {
+			return repository.UserIdentity{}, err
+		}
+		userID = id
+	}
+
+	return s.userRepo.ByID(userID)
+}
+
+func (s Service) adminUser(identity repository.UserIdentity) (AdminUser, error) {
+	user := AdminUser{
+		ID:             identity.ID,
+		Phone:          identity.Phone,
+		CreatedAt:      identity.CreatedAt,
+		PhoneChangedAt: identity.PhoneChangedAt,
+		MFAEnabled:     s.totpEnabled(identity.ID),
+	}
+
+	// linked email is optional
+	user.Email, _ = s.emailOf(identity.ID)
+
+	var err error
+	if user.Role, err = s.adminRole(identity.ID); err != nil {
+		return AdminUser{}, err
+	}
+
+	credentials, err := s.passkeyRepo.Credentials(identity.ID)
+	if err != nil {
+		return AdminUser{}, err
+	}
+	user.Passkeys = len(credentials)
+
+	devices, err := s.devices(identity.ID)
+	if err != nil {
+		return AdminUser{}, err
+	}
+	user.Devices = make([]DeviceInfo, 0, len(devices))
+	for _, device := range devices {
+		user.Devices = append(user.Devices, DeviceInfo{Device: device, Active: s.deviceActive(identity.ID, device.ID)})
+	}
+
+	if user.Ban, err = s.phoneBan(identity.Phone); err != nil {
+		return AdminUser{}, err
+	}
+
+	if user.DeletionScheduledFor, err = s.pendingDeletion(identity.ID); err != nil {
+		return AdminUser{}, err
+	}
+
+	return user, nil
+}
+
+// phoneBan returns the ban of phone, nil when it is not banned.
+func (s Service) phoneBan(phone string) (*PhoneBan, error) {
+	redisAdapter := s.otpRepo.Adapter()
+	data, err := redisAdapter.Client().HGet(redisAdapter.Context(), "phone-bans", phone).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var ban PhoneBan
+	if uErr := json.Unmarshal(data, &ban); uErr != nil {
+		return nil, uErr
+	}
+
+	return &ban, nil
+}
+
+// checkPhoneBan refuses banned numbers with a Forbidden error.
+func (s Service) checkPhoneBan(op richerror.Operation, phone string) error {
+	ban, err := s.phoneBan(phone)
+	if err != nil {
+		return richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+	if ban != nil {
+		return richerror.New(op).WithKind(richerror.KindForbidden).WithMessage(MessagePhoneBanned)
+	}
+
+	return nil
+}
+
+// The HTTP and gRPC handlers count failed codes under these keys and lock
+// the caller out for a while, UnlockLogin lifts them.
+
+func (s Service) OtpAttemptsKey(phone string) string {
+	return "otp_attempts:" + s.normalizePhone(phone)
+}
+
+func (s Service) EmailOtpAttemptsKey(address string) string {
+	return "email_otp_attempts:" + normalizeEmail(address)
+}
+
+func EmailVerifyAttemptsKey(userID string) string {
+	return "email_verify_attempts:" + userID
+}
+
+func PhoneChangeAttemptsKey(userID string) string {
+	return "phone_change_attempts:" + userID
+}
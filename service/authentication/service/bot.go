@@ -0,0 +1,299 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hosseinasadian/chat-application/pkg/richerror"
+	"github.com/redis/go-redis/v9"
+)
+
+const apiKeyPrefix = "bk_"
+
+type Bot struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	OwnerID   string    `json:"owner_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type APIKey struct {
+	ID        string    `json:"id"`
+	BotID     string    `json:"bot_id"`
+	Name      string    `json:"name"`
+	Prefix    string    `json:"prefix"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (s Service) CreateBot(req CreateBotRequest) (CreateBotResponse, error) {
+	const op = "authentication.service.CreateBot"
+
+	if !req.Principal.IsUser() {
+		return CreateBotResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Only users can manage bots")
+	}
+
+	if vErr := s.validator.validateCreateBot(req); vErr != nil {
+		return CreateBotResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage(vErr.Error())
+	}
+
+	bot := Bot{
+		ID:        uuid.NewString(),
+		Name:      req.Name,
+		OwnerID:   req.Principal.Subject,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if sErr := s.saveBot(bot); sErr != nil {
+		return CreateBotResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(sErr)
+	}
+
+	return CreateBotResponse{Bot: bot}, nil
+}
+
+func (s Service) ListBots(req ListBotsRequest) (ListBotsResponse, error) {
+	const op = "authentication.service.ListBots"
+
+	if !req.Principal.IsUser() {
+		return ListBotsResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Only users can manage bots")
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	ids, err := redisAdapter.Client().SMembers(redisAdapter.Context(), "user-bots:"+req.Principal.Subject).Result()
+	if err != nil {
+		return ListBotsResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	bots := make([]Bot, 0, len(ids))
+	for _, id := range ids {
+		bot, gErr := s.getBot(id)
+		if gErr != nil {
+			continue
+		}
+		bots = append(bots, bot)
+	}
+
+	return ListBotsResponse{Bots: bots}, nil
+}
+
+func (s Service) DeleteBot(req DeleteBotRequest) (DeleteBotResponse, error) {
+	const op = "authentication.service.DeleteBot"
+
+	bot, oErr := s.ownedBot(op, req.Principal, req.BotID)
+	if oErr != nil {
+		return DeleteBotResponse{}, oErr
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	keyIDs, err := redisAdapter.Client().SMembers(redisAdapter.Context(), "bot-keys:"+bot.ID).Result()
+	if err != nil {
+		return DeleteBotResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+	for _, keyID := range keyIDs {
+		s.deleteAPIKey(bot.ID, keyID)
+	}
+
+	pipe := redisAdapter.Client().TxPipeline()
+	pipe.Del(redisAdapter.Context(), "bot:"+bot.ID, "bot-keys:"+bot.ID)
+	pipe.SRem(redisAdapter.Context(), "user-bots:"+bot.OwnerID, bot.ID)
+	if _, eErr := pipe.Exec(redisAdapter.Context()); eErr != nil {
+		return DeleteBotResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(eErr)
+	}
+
+	return DeleteBotResponse{Message: "Bot deleted"}, nil
+}
+
+func (s Service) CreateAPIKey(req CreateAPIKeyRequest) (CreateAPIKeyResponse, error) {
+	const op = "authentication.service.CreateAPIKey"
+
+	bot, oErr := s.ownedBot(op, req.Principal, req.BotID)
+	if oErr != nil {
+		return CreateAPIKeyResponse{}, oErr
+	}
+
+	if vErr := s.validator.validateCreateAPIKey(req); vErr != nil {
+		return CreateAPIKeyResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage(vErr.Error())
+	}
+
+	secret := make([]byte, 32)
+	if _, rErr := rand.Read(secret); rErr != nil {
+		return CreateAPIKeyResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(rErr)
+	}
+	plain := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	key := APIKey{
+		ID:        uuid.NewString(),
+		BotID:     bot.ID,
+		Name:      req.Name,
+		Prefix:    plain[:len(apiKeyPrefix)+6],
+		Scopes:    req.Scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if sErr := s.saveAPIKey(key, hashToken(plain)); sErr != nil {
+		return CreateAPIKeyResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(sErr)
+	}
+
+	return CreateAPIKeyResponse{APIKey: key, Key: plain}, nil
+}
+
+func (s Service) ListAPIKeys(req ListAPIKeysRequest) (ListAPIKeysResponse, error) {
+	const op = "authentication.service.ListAPIKeys"
+
+	bot, oErr := s.ownedBot(op, req.Principal, req.BotID)
+	if oErr != nil {
+		return ListAPIKeysResponse{}, oErr
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	keyIDs, err := redisAdapter.Client().SMembers(redisAdapter.Context(), "bot-keys:"+bot.ID).Result()
+	if err != nil {
+		return ListAPIKeysResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	keys := make([]APIKey, 0, len(keyIDs))
+	for _, keyID := range keyIDs {
+		hash, hErr := redisAdapter.Client().Get(redisAdapter.Context(), "apikey-id:"+keyID).Result()
+		if hErr != nil {
+			continue
+		}
+		key, gErr := s.getAPIKey(hash)
+		if gErr != nil {
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	return ListAPIKeysResponse{Keys: keys}, nil
+}
+
+func (s Service) RevokeAPIKey(req RevokeAPIKeyRequest) (RevokeAPIKeyResponse, error) {
+	const op = "authentication.service.RevokeAPIKey"
+
+	bot, oErr := s.ownedBot(op, req.Principal, req.BotID)
+	if oErr != nil {
+		return RevokeAPIKeyResponse{}, oErr
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	isMember, err := redisAdapter.Client().SIsMember(redisAdapter.Context(), "bot-keys:"+bot.ID, req.KeyID).Result()
+	if err != nil {
+		return RevokeAPIKeyResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+	if !isMember {
+		return RevokeAPIKeyResponse{}, richerror.New(op).WithKind(richerror.KindNotFound).WithMessage("API key not found")
+	}
+
+	s.deleteAPIKey(bot.ID, req.KeyID)
+
+	return RevokeAPIKeyResponse{Message: "API key revoked"}, nil
+}
+
+func (s Service) authenticateAPIKey(plain string) (Principal, error) {
+	const op = "authentication/service.authenticateAPIKey"
+
+	if !strings.HasPrefix(plain, apiKeyPrefix) {
+		return Principal{}, richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage("Invalid API key")
+	}
+
+	key, err := s.getAPIKey(hashToken(plain))
+	if err != nil {
+		return Principal{}, richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage("Invalid API key")
+	}
+
+	bot, err := s.getBot(key.BotID)
+	if err != nil {
+		return Principal{}, richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage("Invalid API key")
+	}
+
+	return Principal{
+		Kind:    PrincipalBot,
+		Subject: bot.ID,
+		OwnerID: bot.OwnerID,
+		KeyID:   key.ID,
+		Scopes:  key.Scopes,
+	}, nil
+}
+
+func (s Service) ownedBot(op richerror.Operation, principal Principal, botID string) (Bot, error) {
+	if !principal.IsUser() {
+		return Bot{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Only users can manage bots")
+	}
+
+	bot, err := s.getBot(botID)
+	if errors.Is(err, redis.Nil) || (err == nil && bot.OwnerID != principal.Subject) {
+		return Bot{}, richerror.New(op).WithKind(richerror.KindNotFound).WithMessage("Bot not found")
+	} else if err != nil {
+		return Bot{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	return bot, nil
+}
+
+func (s Service) saveBot(bot Bot) error {
+	data, err := json.Marshal(bot)
+	if err != nil {
+		return err
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	pipe := redisAdapter.Client().TxPipeline()
+	pipe.Set(redisAdapter.Context(), "bot:"+bot.ID, data, 0)
+	pipe.SAdd(redisAdapter.Context(), "user-bots:"+bot.OwnerID, bot.ID)
+	_, err = pipe.Exec(redisAdapter.Context())
+	return err
+}
+
+func (s Service) getBot(botID string) (Bot, error) {
+	redisAdapter := s.otpRepo.Adapter()
+	data, err := redisAdapter.Client().Get(redisAdapter.Context(), "bot:"+botID).Bytes()
+	if err != nil {
+		return Bot{}, err
+	}
+
+	var bot Bot
+	err = json.Unmarshal(data, &bot)
+	return bot, err
+}
+
+func (s Service) saveAPIKey(key APIKey, hash string) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	pipe := redisAdapter.Client().TxPipeline()
+	pipe.Set(redisAdapter.Context(), "apikey:"+hash, data, 0)
+	pipe.Set(redisAdapter.Context(), "apikey-id:"+key.ID, hash, 0)
+	pipe.SAdd(redisAdapter.Context(), "bot-keys:"+key.BotID, key.ID)
+	_, err = pipe.Exec(redisAdapter.Context())
+	return err
+}
+
+func (s Service) getAPIKey(hash string) (APIKey, error) {
+	redisAdapter := s.otpRepo.Adapter()
+	data, err := redisAdapter.Client().Get(redisAdapter.Context(), "apikey:"+hash).Bytes()
+	if err != nil {
+		return APIKey{}, err
+	}
+
+	var key APIKey
+	err = json.Unmarshal(data, &key)
+	return key, err
+}
+
+func (s Service) deleteAPIKey(botID, keyID string) {
+	redisAdapter := s.otpRepo.Adapter()
+	hash, err := redisAdapter.Client().Get(redisAdapter.Context(), "apikey-id:"+keyID).Result()
+	if err == nil {
+		_ = redisAdapter.Client().Del(redisAdapter.Context(), "apikey:"+hash).Err()
+	}
+	_ = redisAdapter.Client().Del(redisAdapter.Context(), "apikey-id:"+keyID).Err()
+	_ = redisAdapter.Client().SRem(redisAdapter.Context(), "bot-keys:"+botID, keyID).Err()
+}
@@ -1,6 +1,10 @@
 package service
 
-import "time"
+import (
+	"time"
+
+	"github.com/hosseinasadian/chat-application/pkg/phone"
+)
 
 type Config struct {
 	AccessTokenSecret  string        `koanf:"access_token_secret"`
@@ -8,4 +12,27 @@ type Config struct {
 	RefreshTokenSecret string        `koanf:"refresh_token_secret"`
 	RefreshTokenTTL    time.Duration `koanf:"refresh_token_ttl"`
 	OTPLength          int           `koanf:"otp_length"`
+	Issuer             string        `koanf:"issuer"`
+	Audience           string        `koanf:"audience"`
+	TokenLeeway        time.Duration `koanf:"token_leeway"`
+	TOTPIssuer         string        `koanf:"totp_issuer"`
+	MFAChallengeTTL    time.Duration `koanf:"mfa_challenge_ttl"`
+
+	// LegacySubjectUntil ends the transition in which tokens whose subject
+	// is a phone number instead of a user id are still accepted.
+	LegacySubjectUntil time.Time `koanf:"legacy_subject_until"`
+
+	WebAuthn WebAuthnConfig `koanf:"webauthn"`
+	OIDC     OIDCConfig     `koanf:"oidc"`
+	Email    EmailConfig    `koanf:"email"`
+	Phone    phone.Config   `koanf:"phone"`
+
+	PhoneChange PhoneChangeConfig `koanf:"phone_change"`
+	Account     AccountConfig     `koanf:"account"`
+	Audit       AuditConfig       `koanf:"audit"`
+	Devices     DevicesConfig     `koanf:"devices"`
+	OtpAbuse    OtpAbuseConfig    `koanf:"otp_abuse"`
+	ProofOfWork ProofOfWorkConfig `koanf:"proof_of_work"`
+
+	IntrospectionClients map[string]string `koanf:"introspection_clients"`
 }
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"crypto/subtle"
+)
+
+// Device ids are issued by the server together with a device secret the
+// client keeps next to its tokens. The secret is presented again when the
+// device logs in or refreshes, so a device id or refresh token taken from one
+// device is worthless on another. Only the hash of the secret is stored, in
+// "device-secrets:<userID>" keyed by device id.
+
+// provenDevice returns deviceID when secret proves the caller is that device
+// of the user, and an empty id otherwise so the login gets a new device.
+func (s Service) provenDevice(userID, deviceID, secret string) string {
+	if deviceID == "" || secret == "" || !s.deviceSecretMatches(userID, deviceID, secret) {
+		return ""
+	}
+
+	return deviceID
+}
+
+// deviceBound reports whether a secret was issued for the device.
+func (s Service) deviceBound(userID, deviceID string) (bool, error) {
+	redisAdapter := s.otpRepo.Adapter()
+	return redisAdapter.Client().HExists(redisAdapter.Context(), "device-secrets:"+userID, deviceID).Result()
+}
+
+func (s Service) deviceSecretMatches(userID, deviceID, secret string) bool {
+	redisAdapter := s.otpRepo.Adapter()
+	stored, err := redisAdapter.Client().HGet(redisAdapter.Context(), "device-secrets:"+userID, deviceID).Result()
+	if err != nil {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(hashToken(secret))) == 1
+}
+
+// bindDevice issues the secret of a device that has none yet and returns it,
+// the only time it is ever seen. A device that already has a secret keeps it
+// and an empty secret is returned.
+func (s Service) bindDevice(userID, deviceID string) (string, error) {
+	secret, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	created, err := redisAdapter.Client().HSetNX(redisAdapter.Context(), "device-secrets:"+userID, deviceID, hashToken(secret)).Result()
+	if err != nil || !created {
+		return "", err
+	}
+
+	return secret, nil
+}
+
+func (s Service) unbindDevice(userID, deviceID string) error {
+	redisAdapter := s.otpRepo.Adapter()
+	return redisAdapter.Client().HDel(redisAdapter.Context(), "device-secrets:"+userID, deviceID).Err()
+}
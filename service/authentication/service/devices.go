@@ -0,0 +1,581 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hosseinasadian/chat-application/pkg/richerror"
+	"github.com/redis/go-redis/v9"
+)
+
+type DevicesConfig struct {
+	ApprovalTTL time.Duration `koanf:"approval_ttl"`
+	// LocationHeader names the header in which the edge proxy passes the
+	// coarse location of the caller, such as a country code. Empty leaves
+	// devices without a location.
+	LocationHeader string `koanf:"location_header"`
+}
+
+const (
+	NotificationNewLogin         = "new_login"
+	NotificationApprovalRequired = "device_approval_required"
+)
+
+const (
+	DeviceApprovalPending  = "pending"
+	DeviceApprovalApproved = "approved"
+	DeviceApprovalDenied   = "denied"
+)
+
+// Device is a client the user logged in from. Trusted devices are the ones
+// allowed to approve new devices; while none is trusted any device with a
+// session may.
+type Device struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name,omitempty"`
+	Trusted    bool      `json:"trusted"`
+	IP         string    `json:"ip,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	Location   string    `json:"location,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+type DeviceInfo struct {
+	Device
+	// Active is set while the device holds a session.
+	Active  bool `json:"active"`
+	Current bool `json:"current"`
+}
+
+type DeviceSettings struct {
+	RequireApproval bool `json:"require_approval"`
+}
+
+// Notification is pushed to the devices of a user as it happens.
+type Notification struct {
+	Type       string    `json:"type"`
+	Device     Device    `json:"device"`
+	ApprovalID string    `json:"approval_id,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// deviceApproval is a login from a new device waiting for an existing one.
+// The new device holds the approval token, "<id>.<secret>".
+type deviceApproval struct {
+	ID         string `json:"id"`
+	UserID     string `json:"user_id"`
+	Device     Device `json:"device"`
+	SecretHash string `json:"secret_hash"`
+	Status     string `json:"status"`
+}
+
+func (s Service) LocationHeader() string {
+	return s.config.Devices.LocationHeader
+}
+
+func (s Service) ListDevices(req ListDevicesRequest) (ListDevicesResponse, error) {
+	const op = "authentication.service.ListDevices"
+
+	if !req.Principal.IsUser() {
+		return ListDevicesResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Only users have devices")
+	}
+
+	devices, err := s.devices(req.Principal.Subject)
+	if err != nil {
+		return ListDevicesResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	infos := make([]DeviceInfo, 0, len(devices))
+	for _, device := range devices {
+		infos = append(infos, DeviceInfo{
+			Device:  device,
+			Active:  s.deviceActive(req.Principal.Subject, device.ID),
+			Current: device.ID == req.Principal.DeviceID,
+		})
+	}
+
+	settings, err := s.deviceSettings(req.Principal.Subject)
+	if err != nil {
+		return ListDevicesResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	return ListDevicesResponse{Devices: infos, Settings: settings}, nil
+}
+
+// UpdateDevice renames a device or changes its trusted flag. Only a device
+// that may approve new devices can change who is trusted.
+func (s Service) UpdateDevice(req UpdateDeviceRequest) (UpdateDeviceResponse, error) {
+	const op = "authentication.service.UpdateDevice"
+
+	if !req.Principal.IsUser() {
+		return UpdateDeviceResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Only users have devices")
+	}
+
+	if vErr := s.validator.validateUpdateDevice(req); vErr != nil {
+		return UpdateDeviceResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage(vErr.Error())
+	}
+
+	userID := req.Principal.Subject
+	device, err := s.getDevice(userID, req.DeviceID)
+	if errors.Is(err, redis.Nil) {
+		return UpdateDeviceResponse{}, richerror.New(op).WithKind(richerror.KindNotFound).WithMessage("Device not found")
+	} else if err != nil {
+		return UpdateDeviceResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	if req.Trusted != nil && *req.Trusted != device.Trusted {
+		canApprove, aErr := s.canApprove(userID, req.Principal.DeviceID)
+		if aErr != nil {
+			return UpdateDeviceResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(aErr)
+		}
+		if !canApprove {
+			return UpdateDeviceResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Only a trusted device can change trusted devices")
+		}
+		device.Trusted = *req.Trusted
+	}
+	if req.Name != nil {
+		device.Name = strings.TrimSpace(*req.Name)
+	}
+
+	if sErr := s.saveDevice(userID, device); sErr != nil {
+		return UpdateDeviceResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(sErr)
+	}
+
+	return UpdateDeviceResponse{Device: device}, nil
+}
+
+// RemoveDevice ends the session of the device and forgets it, its next login
+// counts as a new device.
+func (s Service) RemoveDevice(req RemoveDeviceRequest) (RemoveDeviceResponse, error) {
+	const op = "authentication.service.RemoveDevice"
+
+	if !req.Principal.IsUser() {
+		return RemoveDeviceResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Only users have devices")
+	}
+
+	userID := req.Principal.Subject
+	if _, err := s.getDevice(userID, req.DeviceID); errors.Is(err, redis.Nil) {
+		return RemoveDeviceResponse{}, richerror.New(op).WithKind(richerror.KindNotFound).WithMessage("Device not found")
+	} else if err != nil {
+		return RemoveDeviceResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	if stored, err := s.getRefresh(userID, req.DeviceID); err == nil {
+		claims, pErr := s.parseClaims(stored, TokenTypeRefresh)
+		if pErr == nil {
+			if family, fErr := s.getFamily(claims.FamilyID); fErr == nil {
+				s.revokeFamily(family, SecurityEventDeviceRemoved)
+			}
+		}
+		s.deleteRefresh(userID, req.DeviceID)
+	}
+
+	if uErr := s.unbindDevice(userID, req.DeviceID); uErr != nil {
+		return RemoveDeviceResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(uErr)
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	if dErr := redisAdapter.Client().HDel(redisAdapter.Context(), "devices:"+userID, req.DeviceID).Err(); dErr != nil {
+		return RemoveDeviceResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(dErr)
+	}
+
+	s.emitSecurityEvent(SecurityEvent{
+		Type:     SecurityEventDeviceRemoved,
+		UserID:   userID,
+		DeviceID: req.DeviceID,
+	})
+
+	return RemoveDeviceResponse{Message: "Device removed"}, nil
+}
+
+// UpdateDeviceSettings turns approval of new devices on or off. Like the
+// trusted flag it can only be changed from a device that may approve.
+func (s Service) UpdateDeviceSettings(req UpdateDeviceSettingsRequest) (UpdateDeviceSettingsResponse, error) {
+	const op = "authentication.service.UpdateDeviceSettings"
+
+	if !req.Principal.IsUser() {
+		return UpdateDeviceSettingsResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Only users have devices")
+	}
+
+	userID := req.Principal.Subject
+	canApprove, err := s.canApprove(userID, req.Principal.DeviceID)
+	if err != nil {
+		return UpdateDeviceSettingsResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+	if !canApprove {
+		return UpdateDeviceSettingsResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Only a trusted device can change device settings")
+	}
+
+	settings := DeviceSettings{RequireApproval: req.RequireApproval}
+	if sErr := s.saveJSON("device-settings:"+userID, settings, 0); sErr != nil {
+		return UpdateDeviceSettingsResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(sErr)
+	}
+
+	return UpdateDeviceSettingsResponse{Settings: settings}, nil
+}
+
+// DecideDeviceApproval lets an existing device let a new one in or turn it
+// away.
+func (s Service) DecideDeviceApproval(req DecideDeviceApprovalRequest) (DecideDeviceApprovalResponse, error) {
+	const op = "authentication.service.DecideDeviceApproval"
+
+	if !req.Principal.IsUser() {
+		return DecideDeviceApprovalResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Only users have devices")
+	}
+
+	userID := req.Principal.Subject
+	key := "device-approval:" + req.ApprovalID
+
+	var approval deviceApproval
+	err := s.loadJSON(key, &approval)
+	if errors.Is(err, redis.Nil) || (err == nil && approval.UserID != userID) {
+		return DecideDeviceApprovalResponse{}, richerror.New(op).WithKind(richerror.KindNotFound).WithMessage("Approval request not found")
+	} else if err != nil {
+		return DecideDeviceApprovalResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+	if approval.Status != DeviceApprovalPending {
+		return DecideDeviceApprovalResponse{}, richerror.New(op).WithKind(richerror.KindBadRequest).WithMessage("Approval request was already decided")
+	}
+
+	canApprove, err := s.canApprove(userID, req.Principal.DeviceID)
+	if err != nil {
+		return DecideDeviceApprovalResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+	if !canApprove {
+		return DecideDeviceApprovalResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Only a trusted device can approve new devices")
+	}
+
+	approval.Status = DeviceApprovalDenied
+	eventType := SecurityEventDeviceDenied
+	if req.Approve {
+		approval.Status = DeviceApprovalApproved
+		eventType = SecurityEventDeviceApproved
+	}
+
+	data, err := json.Marshal(approval)
+	if err != nil {
+		return DecideDeviceApprovalResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+	redisAdapter := s.otpRepo.Adapter()
+	if sErr := redisAdapter.Client().Set(redisAdapter.Context(), key, data, redis.KeepTTL).Err(); sErr != nil {
+		return DecideDeviceApprovalResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(sErr)
+	}
+
+	s.emitSecurityEvent(SecurityEvent{
+		Type:       eventType,
+		UserID:     userID,
+		DeviceID:   approval.Device.ID,
+		ClientInfo: ClientInfo{IP: approval.Device.IP, UserAgent: approval.Device.UserAgent, Location: approval.Device.Location},
+	})
+
+	return DecideDeviceApprovalResponse{Status: approval.Status}, nil
+}
+
+// CompleteDeviceApproval is polled by the new device. It answers like
+// VerifyOtp once the login was approved, and with approval_required again
+// while it is pending.
+func (s Service) CompleteDeviceApproval(req CompleteDeviceApprovalRequest) (VerifyOtpResponse, error) {
+	const op = "authentication.service.CompleteDeviceApproval"
+
+	if vErr := s.validator.validateCompleteDeviceApproval(req); vErr != nil {
+		return VerifyOtpResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage(vErr.Error())
+	}
+
+	id, secret, _ := strings.Cut(req.ApprovalToken, ".")
+	key := "device-approval:" + id
+
+	var approval deviceApproval
+	err := s.loadJSON(key, &approval)
+	if errors.Is(err, redis.Nil) || (err == nil && subtle.ConstantTimeCompare([]byte(approval.SecretHash), []byte(hashToken(secret))) != 1) {
+		return VerifyOtpResponse{}, richerror.New(op).WithKind(richerror.KindGone).WithMessage("Approval request has expired")
+	} else if err != nil {
+		return VerifyOtpResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	switch approval.Status {
+	case DeviceApprovalPending:
+		return VerifyOtpResponse{ApprovalRequired: true, ApprovalToken: req.ApprovalToken, DeviceID: approval.Device.ID}, nil
+	case DeviceApprovalDenied:
+		s.deleteKey(key)
+		return VerifyOtpResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Login was denied from another device")
+	}
+
+	// single use, a second caller with the same token finds nothing
+	redisAdapter := s.otpRepo.Adapter()
+	deleted, err := redisAdapter.Client().Del(redisAdapter.Context(), key).Result()
+	if err != nil {
+		return VerifyOtpResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+	if deleted == 0 {
+		return VerifyOtpResponse{}, richerror.New(op).WithKind(richerror.KindGone).WithMessage("Approval request has expired")
+	}
+
+	identity, err := s.userRepo.ByID(approval.UserID)
+	if err != nil {
+		return VerifyOtpResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	client := req.Client
+	if client.IP == "" {
+		client = ClientInfo{IP: approval.Device.IP, UserAgent: approval.Device.UserAgent, Location: approval.Device.Location}
+	}
+
+	return s.completeApprovedLogin(identity, approval.Device.ID, client)
+}
+
+// Notifications streams the notifications of the user until ctx is done.
+func (s Service) Notifications(ctx context.Context, req NotificationsRequest) (<-chan Notification, error) {
+	const op = "authentication.service.Notifications"
+
+	if !req.Principal.IsUser() {
+		return nil, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Only users receive notifications")
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	pubsub := redisAdapter.Client().Subscribe(ctx, "notifications:"+req.Principal.Subject)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	notifications := make(chan Notification)
+	go func() {
+		defer close(notifications)
+		defer pubsub.Close()
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message, ok := <-messages:
+				if !ok {
+					return
+				}
+				var notification Notification
+				if json.Unmarshal([]byte(message.Payload), &notification) != nil {
+					continue
+				}
+				select {
+				case notifications <- notification:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return notifications, nil
+}
+
+// needsDeviceApproval reports whether a login on deviceID has to wait for
+// another device.
+func (s Service) needsDeviceApproval(userID, deviceID string) (bool, error) {
+	settings, err := s.deviceSettings(userID)
+	if err != nil || !settings.RequireApproval {
+		return false, err
+	}
+
+	if _, gErr := s.getDevice(userID, deviceID); gErr == nil {
+		return false, nil
+	} else if !errors.Is(gErr, redis.Nil) {
+		return false, gErr
+	}
+
+	// with no device left to ask, the user would be locked out
+	approvers, err := s.approvers(userID)
+	return len(approvers) > 0, err
+}
+
+// requestDeviceApproval parks the login and asks the other devices.
+func (s Service) requestDeviceApproval(userID, deviceID string, client ClientInfo) (string, error) {
+	secret, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	approval := deviceApproval{
+		ID:         uuid.NewString(),
+		UserID:     userID,
+		Device:     Device{ID: deviceID, IP: client.IP, UserAgent: client.UserAgent, Location: client.Location, CreatedAt: now, LastSeenAt: now},
+		SecretHash: hashToken(secret),
+		Status:     DeviceApprovalPending,
+	}
+	if sErr := s.saveJSON("device-approval:"+approval.ID, approval, s.config.Devices.ApprovalTTL); sErr != nil {
+		return "", sErr
+	}
+
+	s.emitSecurityEvent(SecurityEvent{
+		Type:       SecurityEventDeviceApprovalRequested,
+		UserID:     userID,
+		DeviceID:   deviceID,
+		ClientInfo: client,
+	})
+	s.notify(userID, Notification{Type: NotificationApprovalRequired, Device: approval.Device, ApprovalID: approval.ID})
+
+	return approval.ID + "." + secret, nil
+}
+
+// recordDevice remembers where the user logs in from. A login from a device
+// the user has not used before is announced to the other devices; sessions
+// that predate the device list are recorded quietly when they refresh.
+func (s Service) recordDevice(userID, deviceID string, client ClientInfo, login bool) {
+	now := time.Now().UTC()
+
+	device, err := s.getDevice(userID, deviceID)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return
+	}
+	isNew := err != nil
+	if isNew {
+		device = Device{ID: deviceID, CreatedAt: now}
+	}
+
+	device.LastSeenAt = now
+	if client.IP != "" {
+		device.IP, device.UserAgent = client.IP, client.UserAgent
+	}
+	if client.Location != "" {
+		device.Location = client.Location
+	}
+	if sErr := s.saveDevice(userID, device); sErr != nil || !isNew || !login {
+		return
+	}
+
+	// the very first device has nobody to tell
+	redisAdapter := s.otpRepo.Adapter()
+	if count, cErr := redisAdapter.Client().HLen(redisAdapter.Context(), "devices:"+userID).Result(); cErr != nil || count < 2 {
+		return
+	}
+
+	s.emitSecurityEvent(SecurityEvent{
+		Type:       SecurityEventNewDevice,
+		UserID:     userID,
+		DeviceID:   deviceID,
+		ClientInfo: client,
+	})
+	s.notify(userID, Notification{Type: NotificationNewLogin, Device: device})
+}
+
+// canApprove reports whether deviceID may approve new devices and change
+// which devices are trusted.
+func (s Service) canApprove(userID, deviceID string) (bool, error) {
+	approvers, err := s.approvers(userID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, approver := range approvers {
+		if approver.ID == deviceID {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// approvers are the trusted devices with a session, or every device with a
+// session while none is trusted.
+func (s Service) approvers(userID string) ([]Device, error) {
+	devices, err := s.devices(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var active, trusted []Device
+	for _, device := range devices {
+		if !s.deviceActive(userID, device.ID) {
+			continue
+		}
+		active = append(active, device)
+		if device.Trusted {
+			trusted = append(trusted, device)
+		}
+	}
+
+	if len(trusted) > 0 {
+		return trusted, nil
+	}
+
+	return active, nil
+}
+
+func (s Service) deviceActive(userID, deviceID string) bool {
+	_, err := s.getRefresh(userID, deviceID)
+	return err == nil
+}
+
+func (s Service) devices(userID string) ([]Device, error) {
+	redisAdapter := s.otpRepo.Adapter()
+	stored, err := redisAdapter.Client().HGetAll(redisAdapter.Context(), "devices:"+userID).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	devices := make([]Device, 0, len(stored))
+	for _, data := range stored {
+		var device Device
+		if json.Unmarshal([]byte(data), &device) == nil {
+			devices = append(devices, device)
+		}
+	}
+
+	return devices, nil
+}
+
+func (s Service) getDevice(userID, deviceID string) (Device, error) {
+	redisAdapter := s.otpRepo.Adapter()
+	data, err := redisAdapter.Client().HGet(redisAdapter.Context(), "devices:"+userID, deviceID).Bytes()
+	if err != nil {
+		return Device{}, err
+	}
+
+	var device Device
+	err = json.Unmarshal(data, &device)
+	return device, err
+}
+
+func (s Service) saveDevice(userID string, device Device) error {
+	data, err := json.Marshal(device)
+	if err != nil {
+		return err
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	return redisAdapter.Client().HSet(redisAdapter.Context(), "devices:"+userID, device.ID, data).Err()
+}
+
+func (s Service) deviceSettings(userID string) (DeviceSettings, error) {
+	var settings DeviceSettings
+	if err := s.loadJSON("device-settings:"+userID, &settings); err != nil && !errors.Is(err, redis.Nil) {
+		return DeviceSettings{}, err
+	}
+
+	return settings, nil
+}
+
+func (s Service) notify(userID string, notification Notification) {
+	if notification.OccurredAt.IsZero() {
+		notification.OccurredAt = time.Now().UTC()
+	}
+
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	_ = redisAdapter.Client().Publish(redisAdapter.Context(), "notifications:"+userID, data).Err()
+}
+
+func (s Service) deleteKey(key string) {
+	redisAdapter := s.otpRepo.Adapter()
+	_ = redisAdapter.Client().Del(redisAdapter.Context(), key).Err()
+}
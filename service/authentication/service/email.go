@@ -0,0 +1,315 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/hosseinasadian/chat-application/adapter/email"
+	"github.com/hosseinasadian/chat-application/pkg/richerror"
+	"github.com/redis/go-redis/v9"
+)
+
+const MessageEmailLoginSent = "If the email belongs to an account, a login code and link have been sent"
+
+// EmailSender delivers the login and verification emails, see adapter/email
+// for the SMTP and file drop drivers.
+type EmailSender interface {
+	Send(message email.Message) error
+}
+
+type EmailConfig struct {
+	MagicLinkURL    string        `koanf:"magic_link_url"`
+	LoginTTL        time.Duration `koanf:"login_ttl"`
+	VerificationTTL time.Duration `koanf:"verification_ttl"`
+}
+
+// emailLogin is a pending email login. The code and the magic link are two
+// ways to answer the same challenge, using either one consumes both.
+type emailLogin struct {
+	UserID   string `json:"user_id"`
+	CodeHash string `json:"code_hash"`
+	LinkHash string `json:"link_hash"`
+}
+
+type emailVerification struct {
+	Email    string `json:"email"`
+	CodeHash string `json:"code_hash"`
+}
+
+// LinkEmail sends a verification code to an email the user wants to attach to
+// their account. The email is linked once the code comes back.
+func (s Service) LinkEmail(req LinkEmailRequest) (LinkEmailResponse, error) {
+	const op = "authentication.service.LinkEmail"
+
+	if !req.Principal.IsUser() {
+		return LinkEmailResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Only users can link an email")
+	}
+
+	req.Email = normalizeEmail(req.Email)
+	if vErr := s.validator.validateLinkEmail(req); vErr != nil {
+		return LinkEmailResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage(vErr.Error())
+	}
+
+	owner, err := s.userByEmail(req.Email)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return LinkEmailResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+	if owner != "" && owner != req.Principal.Subject {
+		return LinkEmailResponse{}, richerror.New(op).WithKind(richerror.KindBadRequest).WithMessage("Email is already linked to another account")
+	}
+
+	code, err := randomDigits(6)
+	if err != nil {
+		return LinkEmailResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	verification := emailVerification{Email: req.Email, CodeHash: hashToken(code)}
+	if sErr := s.saveJSON("email-verify:"+req.Principal.Subject, verification, s.config.Email.VerificationTTL); sErr != nil {
+		return LinkEmailResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(sErr)
+	}
+
+	if sErr := s.emailSender.Send(email.Message{
+		To:      req.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Your verification code is %s.\n\nIt expires in %d minutes. If you did not ask to link this email, ignore this message.",
+			code, int(s.config.Email.VerificationTTL.Minutes())),
+	}); sErr != nil {
+		return LinkEmailResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithMessage("Failed to send email").WithWrapper(sErr)
+	}
+
+	return LinkEmailResponse{Message: "Verification code sent"}, nil
+}
+
+func (s Service) VerifyEmail(req VerifyEmailRequest) (VerifyEmailResponse, error) {
+	const op = "authentication.service.VerifyEmail"
+
+	if !req.Principal.IsUser() {
+		return VerifyEmailResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Only users can link an email")
+	}
+
+	if vErr := s.validator.validateVerifyEmail(req); vErr != nil {
+		return VerifyEmailResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage(vErr.Error())
+	}
+
+	userID := req.Principal.Subject
+
+	var verification emailVerification
+	err := s.loadJSON("email-verify:"+userID, &verification)
+	if errors.Is(err, redis.Nil) {
+		return VerifyEmailResponse{}, richerror.New(op).WithKind(richerror.KindGone).WithMessage("Verification code has expired")
+	} else if err != nil {
+		return VerifyEmailResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(verification.CodeHash), []byte(hashToken(req.Code))) != 1 {
+		return VerifyEmailResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage("Invalid verification code")
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	client, ctx := redisAdapter.Client(), redisAdapter.Context()
+	_ = client.Del(ctx, "email-verify:"+userID).Err()
+
+	// SetNX keeps two accounts from claiming the same email at once
+	claimed, err := client.SetNX(ctx, "email:"+verification.Email, userID, 0).Result()
+	if err != nil {
+		return VerifyEmailResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+	if !claimed {
+		owner, gErr := s.userByEmail(verification.Email)
+		if gErr != nil || owner != userID {
+			return VerifyEmailResponse{}, richerror.New(op).WithKind(richerror.KindBadRequest).WithMessage("Email is already linked to another account")
+		}
+	}
+
+	previous, err := s.emailOf(userID)
+	if err == nil && previous != verification.Email {
+		_ = client.Del(ctx, "email:"+previous).Err()
+	}
+	if sErr := client.Set(ctx, "user-email:"+userID, verification.Email, 0).Err(); sErr != nil {
+		return VerifyEmailResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(sErr)
+	}
+
+	return VerifyEmailResponse{Email: verification.Email}, nil
+}
+
+func (s Service) UnlinkEmail(req UnlinkEmailRequest) (UnlinkEmailResponse, error) {
+	const op = "authentication.service.UnlinkEmail"
+
+	if !req.Principal.IsUser() {
+		return UnlinkEmailResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Only users can unlink an email")
+	}
+
+	userID := req.Principal.Subject
+	address, err := s.emailOf(userID)
+	if errors.Is(err, redis.Nil) {
+		return UnlinkEmailResponse{}, richerror.New(op).WithKind(richerror.KindNotFound).WithMessage("No email is linked")
+	} else if err != nil {
+		return UnlinkEmailResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	if dErr := redisAdapter.Client().Del(redisAdapter.Context(), "email:"+address, "user-email:"+userID, "email-login:"+address).Err(); dErr != nil {
+		return UnlinkEmailResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(dErr)
+	}
+
+	return UnlinkEmailResponse{Message: "Email unlinked"}, nil
+}
+
+// SendEmailLogin mails a login code and a magic link to a linked email. The
+// answer is the same whether or not the email is known.
+func (s Service) SendEmailLogin(req SendEmailLoginRequest) (SendEmailLoginResponse, error) {
+	const op = "authentication.service.SendEmailLogin"
+
+	req.Email = normalizeEmail(req.Email)
+	if vErr := s.validator.validateSendEmailLogin(req); vErr != nil {
+		return SendEmailLoginResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage(vErr.Error())
+	}
+
+	userID, err := s.userByEmail(req.Email)
+	if errors.Is(err, redis.Nil) {
+		return SendEmailLoginResponse{Message: MessageEmailLoginSent}, nil
+	} else if err != nil {
+		return SendEmailLoginResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	code, err := randomDigits(6)
+	if err != nil {
+		return SendEmailLoginResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+	link, err := randomToken()
+	if err != nil {
+		return SendEmailLoginResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	ttl := s.config.Email.LoginTTL
+	login := emailLogin{UserID: userID, CodeHash: hashToken(code), LinkHash: hashToken(link)}
+	if sErr := s.saveJSON("email-login:"+req.Email, login, ttl); sErr != nil {
+		return SendEmailLoginResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(sErr)
+	}
+	redisAdapter := s.otpRepo.Adapter()
+	if sErr := redisAdapter.Client().Set(redisAdapter.Context(), "magic-link:"+login.LinkHash, req.Email, ttl).Err(); sErr != nil {
+		return SendEmailLoginResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(sErr)
+	}
+
+	// the link opens a page that posts the token back, so mail scanners that
+	// follow links can not use it up
+	magicLink := redirectWithParams(s.config.Email.MagicLinkURL, map[string]string{"token": link})
+	if sErr := s.emailSender.Send(email.Message{
+		To:      req.Email,
+		Subject: "Your login code",
+		Body: fmt.Sprintf("Your login code is %s.\n\nOr log in with one click:\n%s\n\nThe code and link expire in %d minutes. If you did not try to log in, ignore this message.",
+			code, magicLink, int(ttl.Minutes())),
+	}); sErr != nil {
+		return SendEmailLoginResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithMessage("Failed to send email").WithWrapper(sErr)
+	}
+
+	return SendEmailLoginResponse{Message: MessageEmailLoginSent}, nil
+}
+
+func (s Service) VerifyEmailOtp(req VerifyEmailOtpRequest) (VerifyOtpResponse, error) {
+	const op = "authentication.service.VerifyEmailOtp"
+
+	req.Email = normalizeEmail(req.Email)
+	if vErr := s.validator.validateVerifyEmailOtp(req); vErr != nil {
+		return VerifyOtpResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage(vErr.Error())
+	}
+
+	var login emailLogin
+	err := s.loadJSON("email-login:"+req.Email, &login)
+	if errors.Is(err, redis.Nil) {
+		return VerifyOtpResponse{}, richerror.New(op).WithKind(richerror.KindGone).WithMessage("OTP has expired")
+	} else if err != nil {
+		return VerifyOtpResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(login.CodeHash), []byte(hashToken(req.Otp))) != 1 {
+		return VerifyOtpResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage("Invalid OTP code")
+	}
+
+	if !s.consumeEmailLogin(req.Email, login) {
+		return VerifyOtpResponse{}, richerror.New(op).WithKind(richerror.KindGone).WithMessage("OTP has expired")
+	}
+
+	identity, err := s.userRepo.ByID(login.UserID)
+	if err != nil {
+		return VerifyOtpResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	return s.completeLogin(identity, s.provenDevice(identity.ID, req.DeviceID, req.DeviceSecret), req.Client)
+}
+
+func (s Service) VerifyMagicLink(req VerifyMagicLinkRequest) (VerifyOtpResponse, error) {
+	const op = "authentication.service.VerifyMagicLink"
+
+	if vErr := s.validator.validateVerifyMagicLink(req); vErr != nil {
+		return VerifyOtpResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage(vErr.Error())
+	}
+
+	expired := richerror.New(op).WithKind(richerror.KindGone).WithMessage("Login link has expired")
+	linkHash := hashToken(req.Token)
+
+	redisAdapter := s.otpRepo.Adapter()
+	address, err := redisAdapter.Client().Get(redisAdapter.Context(), "magic-link:"+linkHash).Result()
+	if errors.Is(err, redis.Nil) {
+		return VerifyOtpResponse{}, expired
+	} else if err != nil {
+		return VerifyOtpResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	var login emailLogin
+	if lErr := s.loadJSON("email-login:"+address, &login); lErr != nil || login.LinkHash != linkHash {
+		return VerifyOtpResponse{}, expired
+	}
+
+	if !s.consumeEmailLogin(address, login) {
+		return VerifyOtpResponse{}, expired
+	}
+
+	identity, err := s.userRepo.ByID(login.UserID)
+	if err != nil {
+		return VerifyOtpResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	return s.completeLogin(identity, s.provenDevice(identity.ID, req.DeviceID, req.DeviceSecret), req.Client)
+}
+
+// consumeEmailLogin deletes the pending login and its link. It reports false
+// when another request consumed it first.
+func (s Service) consumeEmailLogin(address string, login emailLogin) bool {
+	redisAdapter := s.otpRepo.Adapter()
+	client, ctx := redisAdapter.Client(), redisAdapter.Context()
+
+	deleted, err := client.Del(ctx, "email-login:"+address).Result()
+	_ = client.Del(ctx, "magic-link:"+login.LinkHash).Err()
+
+	return err == nil && deleted == 1
+}
+
+func (s Service) userByEmail(address string) (string, error) {
+	redisAdapter := s.otpRepo.Adapter()
+	return redisAdapter.Client().Get(redisAdapter.Context(), "email:"+address).Result()
+}
+
+func (s Service) emailOf(userID string) (string, error) {
+	redisAdapter := s.otpRepo.Adapter()
+	return redisAdapter.Client().Get(redisAdapter.Context(), "user-email:"+userID).Result()
+}
+
+func normalizeEmail(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
+
+func randomDigits(n int) (string, error) {
+	limit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+	value, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%0*d", n, value), nil
+}
@@ -0,0 +1,440 @@
+package service
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hosseinasadian/chat-application/pkg/richerror"
+	"github.com/hosseinasadian/chat-application/service/authentication/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+type ExportStatus string
+
+const (
+	ExportStatusPending ExportStatus = "pending"
+	ExportStatusReady   ExportStatus = "ready"
+	ExportStatusFailed  ExportStatus = "failed"
+)
+
+// AccountExport is a requested archive of everything held about the user.
+// Archives are built in the background by RunJobs and kept for ExportTTL.
+type AccountExport struct {
+	Status      ExportStatus `json:"status"`
+	RequestedAt time.Time    `json:"requested_at"`
+	ReadyAt     *time.Time   `json:"ready_at,omitempty"`
+	ExpiresAt   *time.Time   `json:"expires_at,omitempty"`
+	DownloadURL string       `json:"download_url,omitempty"`
+}
+
+// exportDocument is account.json inside the archive.
+type exportDocument struct {
+	GeneratedAt          time.Time               `json:"generated_at"`
+	User                 repository.UserIdentity `json:"user"`
+	Email                string                  `json:"email,omitempty"`
+	TwoFactorEnabled     bool                    `json:"two_factor_enabled"`
+	DeletionScheduledFor *time.Time              `json:"deletion_scheduled_for,omitempty"`
+	Passkeys             []Passkey               `json:"passkeys"`
+	Bots                 []exportedBot           `json:"bots"`
+	OAuthClients         []OAuthClient           `json:"oauth_clients"`
+	OAuthConsents        map[string][]string     `json:"oauth_consents"`
+	Sessions             []exportedSession       `json:"sessions"`
+	Devices              []Device                `json:"devices"`
+	SecurityEvents       []SecurityEvent         `json:"security_events"`
+}
+
+type exportedBot struct {
+	Bot
+	Keys []APIKey `json:"keys"`
+}
+
+type exportedSession struct {
+	DeviceID  string    `json:"device_id"`
+	ClientID  string    `json:"client_id,omitempty"`
+	Scope     string    `json:"scope,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	RotatedAt time.Time `json:"rotated_at"`
+}
+
+// RequestAccountExport queues an archive of the account. A request while
+// another export is being built returns that export.
+func (s Service) RequestAccountExport(req RequestAccountExportRequest) (AccountExportResponse, error) {
+	const op = "authentication.service.RequestAccountExport"
+
+	if !req.Principal.IsUser() {
+		return AccountExportResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Only users can export their account")
+	}
+
+	userID := req.Principal.Subject
+	existing, err := s.getExport(userID)
+	if err == nil && existing.Status == ExportStatusPending {
+		return AccountExportResponse{Export: existing}, nil
+	} else if err != nil && !errors.Is(err, redis.Nil) {
+		return AccountExportResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	export := AccountExport{Status: ExportStatusPending, RequestedAt: time.Now().UTC()}
+	if sErr := s.saveJSON("account-export:"+userID, export, s.config.Account.ExportTTL); sErr != nil {
+		return AccountExportResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(sErr)
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	if pErr := redisAdapter.Client().LPush(redisAdapter.Context(), "export-queue", userID).Err(); pErr != nil {
+		return AccountExportResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(pErr)
+	}
+
+	return AccountExportResponse{Export: export}, nil
+}
+
+func (s Service) GetAccountExport(req GetAccountExportRequest) (AccountExportResponse, error) {
+	const op = "authentication.service.GetAccountExport"
+
+	if !req.Principal.IsUser() {
+		return AccountExportResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Only users can export their account")
+	}
+
+	export, err := s.getExport(req.Principal.Subject)
+	if errors.Is(err, redis.Nil) {
+		return AccountExportResponse{}, richerror.New(op).WithKind(richerror.KindNotFound).WithMessage("No export was requested")
+	} else if err != nil {
+		return AccountExportResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	return AccountExportResponse{Export: export}, nil
+}
+
+// DownloadAccountExport returns the path of a ready archive for the handler
+// to stream.
+func (s Service) DownloadAccountExport(req DownloadAccountExportRequest) (DownloadAccountExportResponse, error) {
+	const op = "authentication.service.DownloadAccountExport"
+
+	if !req.Principal.IsUser() {
+		return DownloadAccountExportResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Only users can export their account")
+	}
+
+	export, err := s.getExport(req.Principal.Subject)
+	if errors.Is(err, redis.Nil) || (err == nil && export.Status != ExportStatusReady) {
+		return DownloadAccountExportResponse{}, richerror.New(op).WithKind(richerror.KindNotFound).WithMessage("Export is not ready")
+	} else if err != nil {
+		return DownloadAccountExportResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	path := s.exportPath(req.Principal.Subject)
+	if _, sErr := os.Stat(path); sErr != nil {
+		return DownloadAccountExportResponse{}, richerror.New(op).WithKind(richerror.KindNotFound).WithMessage("Export is not ready")
+	}
+
+	return DownloadAccountExportResponse{Path: path, FileName: "account-export.zip"}, nil
+}
+
+// exportBuildTimeout is how long an export may be claimed before another
+// run takes it to be lost with a crashed instance and queues it again.
+const exportBuildTimeout = 10 * time.Minute
+
+// claimExport moves the oldest entry of the queue to the exports being built
+// and notes when, ARGV[1] being the current unix time.
+var claimExport = redis.NewScript(`
+local userID = redis.call('LMOVE', KEYS[1], KEYS[2], 'RIGHT', 'LEFT')
+if userID then
+	redis.call('HSET', KEYS[3], userID, ARGV[1])
+end
+return userID
+`)
+
+// requeueExport puts a claimed export back at the head of the queue unless
+// another instance got there first.
+var requeueExport = redis.NewScript(`
+if redis.call('LREM', KEYS[2], 1, ARGV[1]) == 0 then
+	return 0
+end
+redis.call('HDEL', KEYS[3], ARGV[1])
+redis.call('RPUSH', KEYS[1], ARGV[1])
+return 1
+`)
+
+var exportQueueKeys = []string{"export-queue", "export-processing", "export-started"}
+
+// buildPendingExports builds every queued export. Each queue entry is taken
+// by one instance only and stays claimed until its export is built or has
+// failed, exports whose instance died are queued again.
+func (s Service) buildPendingExports() error {
+	redisAdapter := s.otpRepo.Adapter()
+	client, ctx := redisAdapter.Client(), redisAdapter.Context()
+
+	if err := s.requeueStaleExports(); err != nil {
+		return err
+	}
+
+	for {
+		userID, err := claimExport.Run(ctx, client, exportQueueKeys, time.Now().Unix()).Text()
+		if errors.Is(err, redis.Nil) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if bErr := s.buildExport(userID); bErr != nil {
+			log.Printf("account export of %s failed: %v\n", userID, bErr)
+		}
+
+		pipe := client.TxPipeline()
+		pipe.LRem(ctx, "export-processing", 1, userID)
+		pipe.HDel(ctx, "export-started", userID)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// buildExport writes the archive of a claimed export. A failed export is
+// marked so, the user can request a new one.
+func (s Service) buildExport(userID string) error {
+	export, err := s.getExport(userID)
+	if errors.Is(err, redis.Nil) {
+		// cancelled by account deletion or expired while queued
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if export.Status != ExportStatusPending {
+		// built already by an instance taken to have crashed
+		return nil
+	}
+
+	if bErr := s.writeExportArchive(userID); bErr != nil {
+		export.Status = ExportStatusFailed
+		if sErr := s.saveExport(userID, export); sErr != nil {
+			return errors.Join(bErr, sErr)
+		}
+		return bErr
+	}
+
+	now := time.Now().UTC()
+	expiresAt := now.Add(s.config.Account.ExportTTL)
+	export.Status = ExportStatusReady
+	export.ReadyAt = &now
+	export.ExpiresAt = &expiresAt
+	export.DownloadURL = s.config.Account.ExportDownloadURL
+	return s.saveJSON("account-export:"+userID, export, s.config.Account.ExportTTL)
+}
+
+// requeueStaleExports queues the exports claimed longer than
+// exportBuildTimeout ago again.
+func (s Service) requeueStaleExports() error {
+	redisAdapter := s.otpRepo.Adapter()
+	client, ctx := redisAdapter.Client(), redisAdapter.Context()
+
+	claimed, err := client.LRange(ctx, "export-processing", 0, -1).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range claimed {
+		startedAt, sErr := client.HGet(ctx, "export-started", userID).Int64()
+		if sErr != nil && !errors.Is(sErr, redis.Nil) {
+			return sErr
+		}
+		if time.Since(time.Unix(startedAt, 0)) < exportBuildTimeout {
+			continue
+		}
+
+		if rErr := requeueExport.Run(ctx, client, exportQueueKeys, userID).Err(); rErr != nil {
+			return rErr
+		}
+	}
+
+	return nil
+}
+
+func (s Service) writeExportArchive(userID string) error {
+	document, err := s.collectAccountData(userID)
+	if err != nil {
+		return err
+	}
+
+	if mErr := os.MkdirAll(s.config.Account.ExportDir, 0o700); mErr != nil {
+		return mErr
+	}
+
+	// written next to the final name and renamed, a download never sees a
+	// half written archive
+	tmp, err := os.CreateTemp(s.config.Account.ExportDir, "export-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	archive := zip.NewWriter(tmp)
+	entry, err := archive.Create("account.json")
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	encoder := json.NewEncoder(entry)
+	encoder.SetIndent("", "  ")
+	if eErr := encoder.Encode(document); eErr != nil {
+		tmp.Close()
+		return eErr
+	}
+	if cErr := archive.Close(); cErr != nil {
+		tmp.Close()
+		return cErr
+	}
+	if cErr := tmp.Close(); cErr != nil {
+		return cErr
+	}
+
+	return os.Rename(tmp.Name(), s.exportPath(userID))
+}
+
+func (s Service) collectAccountData(userID string) (exportDocument, error) {
+	identity, err := s.userRepo.ByID(userID)
+	if err != nil {
+		return exportDocument{}, err
+	}
+
+	principal := Principal{Kind: PrincipalUser, Subject: userID, Phone: identity.Phone}
+	document := exportDocument{
+		GeneratedAt:      time.Now().UTC(),
+		User:             identity,
+		TwoFactorEnabled: s.totpEnabled(userID),
+		Passkeys:         []Passkey{},
+		Bots:             []exportedBot{},
+		OAuthConsents:    map[string][]string{},
+		Sessions:         []exportedSession{},
+		SecurityEvents:   []SecurityEvent{},
+	}
+	document.Email, _ = s.emailOf(userID)
+
+	if document.DeletionScheduledFor, err = s.pendingDeletion(userID); err != nil {
+		return exportDocument{}, err
+	}
+
+	if document.Devices, err = s.devices(userID); err != nil {
+		return exportDocument{}, err
+	}
+
+	credentials, err := s.passkeyRepo.Credentials(userID)
+	if err != nil {
+		return exportDocument{}, err
+	}
+	for _, credential := range credentials {
+		document.Passkeys = append(document.Passkeys, passkeyFrom(credential))
+	}
+
+	bots, err := s.ListBots(ListBotsRequest{Principal: principal})
+	if err != nil {
+		return exportDocument{}, err
+	}
+	for _, bot := range bots.Bots {
+		keys, kErr := s.ListAPIKeys(ListAPIKeysRequest{Principal: principal, BotID: bot.ID})
+		if kErr != nil {
+			return exportDocument{}, kErr
+		}
+		document.Bots = append(document.Bots, exportedBot{Bot: bot, Keys: keys.Keys})
+	}
+
+	clients, err := s.ListOAuthClients(ListOAuthClientsRequest{Principal: principal})
+	if err != nil {
+		return exportDocument{}, err
+	}
+	document.OAuthClients = clients.Clients
+
+	redisAdapter := s.otpRepo.Adapter()
+	client, ctx := redisAdapter.Client(), redisAdapter.Context()
+
+	consentPrefix := "oauth-consent:" + userID + ":"
+	if sErr := s.scanKeys(consentPrefix, func(key string) error {
+		scopes, mErr := client.SMembers(ctx, key).Result()
+		document.OAuthConsents[strings.TrimPrefix(key, consentPrefix)] = scopes
+		return mErr
+	}); sErr != nil {
+		return exportDocument{}, sErr
+	}
+
+	if sErr := s.scanKeys("refresh:"+userID+":", func(key string) error {
+		stored, gErr := client.Get(ctx, key).Result()
+		if gErr != nil {
+			return ignoreNil(gErr)
+		}
+		claims, pErr := s.parseClaims(stored, TokenTypeRefresh)
+		if pErr != nil {
+			return nil
+		}
+		family, fErr := s.getFamily(claims.FamilyID)
+		if fErr != nil {
+			return ignoreNil(fErr)
+		}
+
+		document.Sessions = append(document.Sessions, exportedSession{
+			DeviceID:  family.DeviceID,
+			ClientID:  family.ClientID,
+			Scope:     family.Scope,
+			CreatedAt: family.CreatedAt,
+			RotatedAt: family.RotatedAt,
+		})
+		return nil
+	}); sErr != nil {
+		return exportDocument{}, sErr
+	}
+
+	events, err := client.LRange(ctx, "security-events:"+userID, 0, -1).Result()
+	if err != nil {
+		return exportDocument{}, err
+	}
+	for _, data := range events {
+		var event SecurityEvent
+		if json.Unmarshal([]byte(data), &event) == nil {
+			document.SecurityEvents = append(document.SecurityEvents, event)
+		}
+	}
+
+	return document, nil
+}
+
+func (s Service) getExport(userID string) (AccountExport, error) {
+	var export AccountExport
+	err := s.loadJSON("account-export:"+userID, &export)
+	return export, err
+}
+
+func (s Service) saveExport(userID string, export AccountExport) error {
+	data, err := json.Marshal(export)
+	if err != nil {
+		return err
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	return redisAdapter.Client().Set(redisAdapter.Context(), "account-export:"+userID, data, redis.KeepTTL).Err()
+}
+
+func (s Service) exportPath(userID string) string {
+	return filepath.Join(s.config.Account.ExportDir, userID+".zip")
+}
+
+func (s Service) removeExportArchive(userID string) {
+	_ = os.Remove(s.exportPath(userID))
+}
+
+// removeExpiredExports deletes archives older than ExportTTL, their status
+// in Redis expires on its own.
+func (s Service) removeExpiredExports() {
+	entries, err := os.ReadDir(s.config.Account.ExportDir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		info, iErr := entry.Info()
+		if iErr != nil || time.Since(info.ModTime()) < s.config.Account.ExportTTL {
+			continue
+		}
+		_ = os.Remove(filepath.Join(s.config.Account.ExportDir, entry.Name()))
+	}
+}
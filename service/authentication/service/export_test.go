@@ -0,0 +1,104 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func requestTestExport(t *testing.T, s Service, principal Principal) {
+	t.Helper()
+
+	if _, err := s.RequestAccountExport(RequestAccountExportRequest{Principal: principal}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func exportStatus(t *testing.T, s Service, principal Principal) ExportStatus {
+	t.Helper()
+
+	res, err := s.GetAccountExport(GetAccountExportRequest{Principal: principal})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res.Export.Status
+}
+
+func TestExportOfCrashedBuildIsQueuedAgain(t *testing.T) {
+	s, identity := newTestService(t)
+	s.config.Account.ExportDir = t.TempDir()
+	principal := Principal{Kind: PrincipalUser, Subject: identity.ID, Phone: identity.Phone}
+	requestTestExport(t, s, principal)
+
+	// an instance claims the export and dies before building it
+	redisAdapter := s.otpRepo.Adapter()
+	client, ctx := redisAdapter.Client(), redisAdapter.Context()
+	startedAt := time.Now().Add(-exportBuildTimeout - time.Minute).Unix()
+	if err := claimExport.Run(ctx, client, exportQueueKeys, startedAt).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.buildPendingExports(); err != nil {
+		t.Fatal(err)
+	}
+	if status := exportStatus(t, s, principal); status != ExportStatusReady {
+		t.Fatalf("status = %q, want %q", status, ExportStatusReady)
+	}
+	if _, err := os.Stat(s.exportPath(identity.ID)); err != nil {
+		t.Errorf("archive: %v", err)
+	}
+	if n := client.LLen(ctx, "export-processing").Val(); n != 0 {
+		t.Errorf("%d exports left claimed", n)
+	}
+}
+
+func TestExportInProgressIsNotQueuedAgain(t *testing.T) {
+	s, identity := newTestService(t)
+	s.config.Account.ExportDir = t.TempDir()
+	principal := Principal{Kind: PrincipalUser, Subject: identity.ID, Phone: identity.Phone}
+	requestTestExport(t, s, principal)
+
+	redisAdapter := s.otpRepo.Adapter()
+	client, ctx := redisAdapter.Client(), redisAdapter.Context()
+	if err := claimExport.Run(ctx, client, exportQueueKeys, time.Now().Unix()).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.buildPendingExports(); err != nil {
+		t.Fatal(err)
+	}
+	if status := exportStatus(t, s, principal); status != ExportStatusPending {
+		t.Errorf("status = %q, want %q", status, ExportStatusPending)
+	}
+}
+
+func TestFailedExportCanBeRequestedAgain(t *testing.T) {
+	s, identity := newTestService(t)
+	principal := Principal{Kind: PrincipalUser, Subject: identity.ID, Phone: identity.Phone}
+
+	// the export directory can not be created below a file
+	blocker := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(blocker, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s.config.Account.ExportDir = filepath.Join(blocker, "exports")
+	requestTestExport(t, s, principal)
+
+	if err := s.buildPendingExports(); err != nil {
+		t.Fatal(err)
+	}
+	if status := exportStatus(t, s, principal); status != ExportStatusFailed {
+		t.Fatalf("status = %q, want %q", status, ExportStatusFailed)
+	}
+
+	s.config.Account.ExportDir = t.TempDir()
+	requestTestExport(t, s, principal)
+	if err := s.buildPendingExports(); err != nil {
+		t.Fatal(err)
+	}
+	if status := exportStatus(t, s, principal); status != ExportStatusReady {
+		t.Errorf("status = %q, want %q", status, ExportStatusReady)
+	}
+}
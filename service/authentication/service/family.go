@@ -0,0 +1,231 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hosseinasadian/chat-application/pkg/richerror"
+	"github.com/hosseinasadian/chat-application/service/authentication/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	MessageSuspiciousActivity = "Your session was terminated because of suspicious activity"
+	MessageSessionExpired     = "Your session has expired, please log in again"
+	MessagePhoneChanged       = "Your phone number was changed, please log in again"
+	MessageAccountDeleted     = "Your account was deleted"
+	MessageDeviceRemoved      = "This device was removed from your account"
+	MessageAdminRevoked       = "Your session was ended by an administrator"
+	MessagePhoneBanned        = "This phone number is banned"
+)
+
+// TokenFamily links every refresh token rotated from one login. Only the
+// token whose jti is CurrentJTI may be exchanged, presenting any older member
+// of the family is treated as theft and revokes the family as a whole.
+//
+// Families started before user ids were introduced have no UserID, their
+// tokens carry the phone number as subject until the next rotation.
+//
+// A family started through the OAuth token endpoint remembers the client and
+// the granted scope, so every token rotated from it stays restricted.
+type TokenFamily struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id,omitempty"`
+	Phone      string    `json:"phone"`
+	DeviceID   string    `json:"device_id"`
+	ClientID   string    `json:"client_id,omitempty"`
+	Scope      string    `json:"scope,omitempty"`
+	CurrentJTI string    `json:"current_jti"`
+	CreatedAt  time.Time `json:"created_at"`
+	RotatedAt  time.Time `json:"rotated_at"`
+}
+
+func newTokenFamily(identity repository.UserIdentity, deviceID string) TokenFamily {
+	now := time.Now().UTC()
+	return TokenFamily{
+		ID:        uuid.NewString(),
+		UserID:    identity.ID,
+		Phone:     identity.Phone,
+		DeviceID:  deviceID,
+		CreatedAt: now,
+		RotatedAt: now,
+	}
+}
+
+// subject is what the tokens of the family are issued to.
+func (f TokenFamily) subject() string {
+	if f.UserID == "" {
+		return f.Phone
+	}
+
+	return f.UserID
+}
+
+func (s Service) saveFamily(family TokenFamily) error {
+	data, err := json.Marshal(family)
+	if err != nil {
+		return err
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	return redisAdapter.Client().Set(redisAdapter.Context(), "family:"+family.ID, data, s.config.RefreshTokenTTL).Err()
+}
+
+func (s Service) getFamily(familyID string) (TokenFamily, error) {
+	redisAdapter := s.otpRepo.Adapter()
+	data, err := redisAdapter.Client().Get(redisAdapter.Context(), "family:"+familyID).Bytes()
+	if err != nil {
+		return TokenFamily{}, err
+	}
+
+	var family TokenFamily
+	err = json.Unmarshal(data, &family)
+	return family, err
+}
+
+// revokeFamily kills every token of the family. The marker outlives the
+// longest token so access tokens issued from the family are refused too.
+func (s Service) revokeFamily(family TokenFamily, reason string) {
+	redisAdapter := s.otpRepo.Adapter()
+	ttl := max(s.config.RefreshTokenTTL, s.config.AccessTokenTTL)
+	_ = redisAdapter.Client().Set(redisAdapter.Context(), "family-revoked:"+family.ID, reason, ttl).Err()
+	_ = redisAdapter.Client().Del(redisAdapter.Context(), "family:"+family.ID).Err()
+
+	if family.CurrentJTI != "" {
+		s.blacklistJTI(family.CurrentJTI)
+	}
+
+	s.emitSecurityEvent(SecurityEvent{
+		Type:     SecurityEventSessionRevoked,
+		UserID:   family.subject(),
+		DeviceID: family.DeviceID,
+		FamilyID: family.ID,
+		Reason:   reason,
+	})
+
+	// only drop the device session if it still belongs to this family, a newer
+	// login on the same device must survive
+	stored, err := s.getRefresh(family.subject(), family.DeviceID)
+	if err != nil {
+		return
+	}
+	if claims, pErr := s.parseClaims(stored, TokenTypeRefresh); pErr != nil || claims.FamilyID == family.ID {
+		s.deleteRefresh(family.subject(), family.DeviceID)
+	}
+}
+
+// revokeAllFamilies revokes every session stored under subjects, used when
+// all of a user's devices have to log in again.
+func (s Service) revokeAllFamilies(reason string, subjects ...string) {
+	redisAdapter := s.otpRepo.Adapter()
+	for _, subject := range subjects {
+		if subject == "" {
+			continue
+		}
+
+		_ = s.scanKeys("refresh:"+subject+":", func(key string) error {
+			stored, err := redisAdapter.Client().Get(redisAdapter.Context(), key).Result()
+			if err != nil {
+				return nil
+			}
+
+			if claims, pErr := s.parseClaims(stored, TokenTypeRefresh); pErr == nil {
+				if family, fErr := s.getFamily(claims.FamilyID); fErr == nil {
+					s.revokeFamily(family, reason)
+					return nil
+				}
+			}
+
+			return redisAdapter.Client().Del(redisAdapter.Context(), key).Err()
+		})
+	}
+}
+
+// checkSession fails unless the session an access token was issued for is
+// still current: its family is not revoked and the latest refresh token of
+// the device belongs to it, which a logout or a new login on the device ends.
+// Authenticate, ValidateToken and introspection all go through it so they
+// agree on every token.
+func (s Service) checkSession(op richerror.Operation, claims *Claims) error {
+	if s.isFamilyRevoked(claims.FamilyID) {
+		return richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage(s.revokedFamilyMessage(claims.FamilyID))
+	}
+
+	stored, err := s.getRefresh(claims.Subject, claims.DeviceID)
+	// the session of a legacy token moves from its phone subject to the user
+	// id on the first rotation
+	if errors.Is(err, redis.Nil) && claims.Phone == "" {
+		if userID := s.existingUserID(s.normalizePhone(claims.Subject)); userID != "" {
+			stored, err = s.getRefresh(userID, claims.DeviceID)
+		}
+	}
+	if errors.Is(err, redis.Nil) {
+		return richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage(MessageSessionExpired)
+	} else if err != nil {
+		return richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	current, err := s.parseClaims(stored, TokenTypeRefresh)
+	if err != nil || current.FamilyID != claims.FamilyID {
+		return richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage(MessageSessionExpired)
+	}
+
+	return nil
+}
+
+// revokedFamilyMessage tells the holder of a token from a revoked family why
+// the session ended.
+func (s Service) revokedFamilyMessage(familyID string) string {
+	redisAdapter := s.otpRepo.Adapter()
+	reason, _ := redisAdapter.Client().Get(redisAdapter.Context(), "family-revoked:"+familyID).Result()
+	switch reason {
+	case SecurityEventPhoneChanged:
+		return MessagePhoneChanged
+	case SecurityEventAccountDeleted:
+		return MessageAccountDeleted
+	case SecurityEventDeviceRemoved:
+		return MessageDeviceRemoved
+	case SecurityEventAdminSessionsRevoked:
+		return MessageAdminRevoked
+	case SecurityEventAdminPhoneBanned:
+		return MessagePhoneBanned
+	default:
+		return MessageSuspiciousActivity
+	}
+}
+
+func (s Service) isFamilyRevoked(familyID string) bool {
+	redisAdapter := s.otpRepo.Adapter()
+	_, err := redisAdapter.Client().Get(redisAdapter.Context(), "family-revoked:"+familyID).Result()
+	return !errors.Is(err, redis.Nil)
+}
+
+// revokeFamilyOnDeviceMismatch ends a session whose refresh token was
+// presented without the secret of its device, the token left the device.
+func (s Service) revokeFamilyOnDeviceMismatch(family TokenFamily, jti string, client ClientInfo) {
+	s.blacklistJTI(jti)
+	s.emitSecurityEvent(SecurityEvent{
+		Type:       SecurityEventDeviceMismatch,
+		UserID:     family.subject(),
+		DeviceID:   family.DeviceID,
+		FamilyID:   family.ID,
+		JTI:        jti,
+		ClientInfo: client,
+	})
+	s.revokeFamily(family, SecurityEventDeviceMismatch)
+}
+
+func (s Service) revokeFamilyOnReuse(family TokenFamily, jti string, client ClientInfo) {
+	s.blacklistJTI(jti)
+	s.emitSecurityEvent(SecurityEvent{
+		Type:       SecurityEventRefreshReuse,
+		UserID:     family.subject(),
+		DeviceID:   family.DeviceID,
+		FamilyID:   family.ID,
+		JTI:        jti,
+		ClientInfo: client,
+	})
+	s.revokeFamily(family, SecurityEventRefreshReuse)
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/hosseinasadian/chat-application/pkg/richerror"
+)
+
+const (
+	TokenTypeHintAccess  = "access_token"
+	TokenTypeHintRefresh = "refresh_token"
+	TokenTypeHintAPIKey  = "api_key"
+)
+
+// AuthenticateClient checks the credentials of a service allowed to call the
+// introspection endpoint.
+func (s Service) AuthenticateClient(clientID, clientSecret string) error {
+	const op = "authentication.service.AuthenticateClient"
+
+	expected, ok := s.config.IntrospectionClients[clientID]
+	if !ok || expected == "" || subtle.ConstantTimeCompare([]byte(expected), []byte(clientSecret)) != 1 {
+		return richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage("Invalid client credentials")
+	}
+
+	return nil
+}
+
+// Introspect reports the state of a token as described in RFC 7662. Any token
+// that is malformed, expired or revoked is returned as inactive.
+func (s Service) Introspect(req IntrospectRequest) (IntrospectResponse, error) {
+	const op = "authentication.service.Introspect"
+
+	if vErr := s.validator.validateIntrospect(req); vErr != nil {
+		return IntrospectResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage(vErr.Error())
+	}
+
+	if strings.HasPrefix(req.Token, apiKeyPrefix) {
+		return s.introspectAPIKey(req.Token), nil
+	}
+
+	switch req.TokenTypeHint {
+	case TokenTypeHintRefresh:
+		if res := s.introspectRefresh(req.Token); res.Active {
+			return res, nil
+		}
+		return s.introspectAccess(req.Token), nil
+	default:
+		if res := s.introspectAccess(req.Token); res.Active {
+			return res, nil
+		}
+		return s.introspectRefresh(req.Token), nil
+	}
+}
+
+func (s Service) introspectAccess(token string) IntrospectResponse {
+	const op = "authentication.service.introspectAccess"
+
+	claims, err := s.parseClaims(token, TokenTypeAccess)
+	if err != nil {
+		return IntrospectResponse{Active: false}
+	}
+
+	// a logged out device is reported inactive before its token expires
+	if sErr := s.checkSession(op, claims); sErr != nil {
+		return IntrospectResponse{Active: false}
+	}
+
+	return s.introspectClaims(claims, TokenTypeHintAccess)
+}
+
+func (s Service) introspectRefresh(token string) IntrospectResponse {
+	claims, err := s.parseClaims(token, TokenTypeRefresh)
+	if err != nil || s.isBlacklisted(claims.ID) || s.isFamilyRevoked(claims.FamilyID) {
+		return IntrospectResponse{Active: false}
+	}
+
+	stored, gErr := s.getRefresh(claims.Subject, claims.DeviceID)
+	if gErr != nil || stored != token {
+		return IntrospectResponse{Active: false}
+	}
+
+	return s.introspectClaims(claims, TokenTypeHintRefresh)
+}
+
+func (s Service) introspectAPIKey(token string) IntrospectResponse {
+	principal, err := s.authenticateAPIKey(token)
+	if err != nil {
+		return IntrospectResponse{Active: false}
+	}
+
+	return IntrospectResponse{
+		Active:    true,
+		TokenType: TokenTypeHintAPIKey,
+		Subject:   principal.Subject,
+		Scope:     strings.Join(principal.Scopes, " "),
+		OwnerID:   principal.OwnerID,
+		KeyID:     principal.KeyID,
+	}
+}
+
+// introspectClaims reports the user id as subject, also for legacy tokens
+// that carry the phone number.
+func (s Service) introspectClaims(claims *Claims, tokenType string) IntrospectResponse {
+	identity, err := s.identityOf(claims)
+	if err != nil {
+		return IntrospectResponse{Active: false}
+	}
+
+	res := introspectResponseFromClaims(claims, tokenType)
+	res.Subject = identity.ID
+	return res
+}
+
+func introspectResponseFromClaims(claims *Claims, tokenType string) IntrospectResponse {
+	res := IntrospectResponse{
+		Active:    true,
+		TokenType: tokenType,
+		Subject:   claims.Subject,
+		DeviceID:  claims.DeviceID,
+		JTI:       claims.ID,
+		ClientID:  claims.ClientID,
+		Scope:     claims.Scope,
+		Issuer:    claims.Issuer,
+		Audience:  claims.Audience,
+	}
+
+	if claims.ExpiresAt != nil {
+		res.ExpiresAt = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		res.IssuedAt = claims.IssuedAt.Unix()
+	}
+
+	return res
+}
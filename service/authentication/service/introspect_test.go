@@ -0,0 +1,100 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// tokenState asks introspection and ValidateToken about the same access
+// token, they have to agree.
+func tokenState(t *testing.T, s Service, access string) bool {
+	t.Helper()
+
+	introspected, err := s.Introspect(IntrospectRequest{Token: access, TokenTypeHint: TokenTypeHintAccess})
+	if err != nil {
+		t.Fatal(err)
+	}
+	validated, err := s.ValidateToken(ValidateTokenRequest{Token: access})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if introspected.Active != validated.Valid {
+		t.Fatalf("introspection active = %v, ValidateToken valid = %v", introspected.Active, validated.Valid)
+	}
+
+	return validated.Valid
+}
+
+func TestAccessTokenEndsWithLogout(t *testing.T) {
+	s, identity := newTestService(t)
+
+	pair, err := s.issueSession(identity, "device-1", ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tokenState(t, s, pair.AccessToken) {
+		t.Fatal("fresh access token is inactive")
+	}
+
+	principal, err := s.Authenticate("Bearer " + pair.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Logout(LogoutRequest{Principal: principal}); err != nil {
+		t.Fatal(err)
+	}
+
+	if tokenState(t, s, pair.AccessToken) {
+		t.Error("access token still active after logout")
+	}
+	if _, err := s.Authenticate("Bearer " + pair.AccessToken); err == nil {
+		t.Error("access token authenticates after logout")
+	}
+}
+
+func TestAccessTokenEndsWithNewLoginOnDevice(t *testing.T) {
+	s, identity := newTestService(t)
+
+	first, err := s.issueSession(identity, "device-1", ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.issueSession(identity, "device-1", ClientInfo{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if tokenState(t, s, first.AccessToken) {
+		t.Error("access token of the replaced session still active")
+	}
+}
+
+func TestLegacyAccessTokenAfterMigration(t *testing.T) {
+	s, identity := newTestService(t)
+	s.config.LegacySubjectUntil = time.Now().Add(time.Hour)
+
+	// a session started before user ids, then rotated to the user id key
+	family := TokenFamily{ID: "legacy-family", Phone: identity.Phone, DeviceID: "device-1"}
+	if err := s.saveFamily(family); err != nil {
+		t.Fatal(err)
+	}
+
+	claims := s.newClaims(TokenTypeAccess, family, time.Minute)
+	claims.Phone = ""
+	access := sign(t, jwt.SigningMethodHS256, claims, []byte(s.config.AccessTokenSecret))
+
+	family.UserID = identity.ID
+	refresh, _, err := s.issueRefresh(family)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.saveRefresh(identity.ID, family.DeviceID, refresh); err != nil {
+		t.Fatal(err)
+	}
+
+	if !tokenState(t, s, access) {
+		t.Error("legacy access token inactive once its session moved to the user id")
+	}
+}
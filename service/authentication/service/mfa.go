@@ -0,0 +1,356 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hosseinasadian/chat-application/pkg/richerror"
+	"github.com/hosseinasadian/chat-application/pkg/totp"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	recoveryCodeCount    = 10
+	mfaChallengeAttempts = 5
+	totpSkew             = 1
+
+	// totpDisableAttempts codes may be tried to turn two-factor
+	// authentication off within totpDisableLockout, then the user waits.
+	totpDisableAttempts = 5
+	totpDisableLockout  = 15 * time.Minute
+)
+
+// consumeTOTPStep moves the last step used by the user forward to ARGV[1]
+// and fails when that step or a later one was used already, so every code
+// passes once even when requests race. ARGV[2] is the last step recorded in
+// the enrollment before the steps were kept apart from it.
+var consumeTOTPStep = redis.NewScript(`
+local last = math.max(tonumber(redis.call('GET', KEYS[1]) or '0'), tonumber(ARGV[2]))
+if tonumber(ARGV[1]) <= last then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1])
+return 1
+`)
+
+type totpEnrollment struct {
+	Secret         string    `json:"secret"`
+	Enabled        bool      `json:"enabled"`
+	RecoveryHashes []string  `json:"recovery_hashes"`
+	LastStep       int64     `json:"last_step"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type mfaChallenge struct {
+	UserID   string `json:"user_id"`
+	DeviceID string `json:"device_id"`
+}
+
+// EnrollTOTP starts TOTP enrollment. The secret only protects logins once it
+// is confirmed with a code from the authenticator app.
+func (s Service) EnrollTOTP(req EnrollTOTPRequest) (EnrollTOTPResponse, error) {
+	const op = "authentication.service.EnrollTOTP"
+
+	if !req.Principal.IsUser() {
+		return EnrollTOTPResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Only users can enroll two-factor authentication")
+	}
+
+	userID := req.Principal.Subject
+	if s.totpEnabled(userID) {
+		return EnrollTOTPResponse{}, richerror.New(op).WithKind(richerror.KindBadRequest).WithMessage("Two-factor authentication is already enabled")
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return EnrollTOTPResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return EnrollTOTPResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	enrollment := totpEnrollment{
+		Secret:         secret,
+		RecoveryHashes: hashes,
+		CreatedAt:      time.Now().UTC(),
+	}
+	if sErr := s.saveTOTP(userID, enrollment); sErr != nil {
+		return EnrollTOTPResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(sErr)
+	}
+
+	uri := totp.ProvisioningURI(s.config.TOTPIssuer, req.Principal.Phone, secret)
+
+	return EnrollTOTPResponse{
+		Secret:          secret,
+		ProvisioningURI: uri,
+		QRPayload:       uri,
+		RecoveryCodes:   codes,
+	}, nil
+}
+
+func (s Service) ConfirmTOTP(req ConfirmTOTPRequest) (ConfirmTOTPResponse, error) {
+	const op = "authentication.service.ConfirmTOTP"
+
+	if !req.Principal.IsUser() {
+		return ConfirmTOTPResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Only users can enroll two-factor authentication")
+	}
+
+	if vErr := s.validator.validateTOTPCode(req.Code); vErr != nil {
+		return ConfirmTOTPResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage(vErr.Error())
+	}
+
+	userID := req.Principal.Subject
+	enrollment, err := s.getTOTP(userID)
+	if errors.Is(err, redis.Nil) {
+		return ConfirmTOTPResponse{}, richerror.New(op).WithKind(richerror.KindNotFound).WithMessage("Two-factor enrollment not found")
+	} else if err != nil {
+		return ConfirmTOTPResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+	if enrollment.Enabled {
+		return ConfirmTOTPResponse{}, richerror.New(op).WithKind(richerror.KindBadRequest).WithMessage("Two-factor authentication is already enabled")
+	}
+
+	step, ok := totp.Validate(enrollment.Secret, req.Code, time.Now(), totpSkew)
+	if !ok {
+		return ConfirmTOTPResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage("Invalid two-factor code")
+	}
+
+	enrollment.Enabled = true
+	enrollment.LastStep = step
+	if sErr := s.saveTOTP(userID, enrollment); sErr != nil {
+		return ConfirmTOTPResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(sErr)
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	if sErr := redisAdapter.Client().Set(redisAdapter.Context(), "totp-step:"+userID, step, 0).Err(); sErr != nil {
+		return ConfirmTOTPResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(sErr)
+	}
+
+	return ConfirmTOTPResponse{Message: "Two-factor authentication enabled"}, nil
+}
+
+func (s Service) DisableTOTP(req DisableTOTPRequest) (DisableTOTPResponse, error) {
+	const op = "authentication.service.DisableTOTP"
+
+	if !req.Principal.IsUser() {
+		return DisableTOTPResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Only users can manage two-factor authentication")
+	}
+
+	userID := req.Principal.Subject
+	enrollment, err := s.getTOTP(userID)
+	if errors.Is(err, redis.Nil) || (err == nil && !enrollment.Enabled) {
+		return DisableTOTPResponse{}, richerror.New(op).WithKind(richerror.KindNotFound).WithMessage("Two-factor authentication is not enabled")
+	} else if err != nil {
+		return DisableTOTPResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	// a stolen access token must not be enough to guess the codes, attempts
+	// are counted before the code is checked like those of a challenge
+	attemptsKey := "totp-disable:" + userID
+	attempts, err := s.countMFAAttempt(attemptsKey, totpDisableLockout)
+	if err != nil {
+		return DisableTOTPResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+	if attempts > totpDisableAttempts {
+		return DisableTOTPResponse{}, richerror.New(op).WithKind(richerror.KindTooManyRequests).WithMessage("Too many attempts")
+	}
+
+	if ok, cErr := s.checkSecondFactor(userID, enrollment, req.Code); cErr != nil {
+		return DisableTOTPResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(cErr)
+	} else if !ok {
+		return DisableTOTPResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage("Invalid two-factor code")
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	if dErr := redisAdapter.Client().Del(redisAdapter.Context(), "totp:"+userID, "totp-step:"+userID, "totp-recovery-used:"+userID, attemptsKey+":attempts").Err(); dErr != nil {
+		return DisableTOTPResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(dErr)
+	}
+
+	return DisableTOTPResponse{Message: "Two-factor authentication disabled"}, nil
+}
+
+// VerifyMFA completes a login that VerifyOtp answered with mfa_required. The
+// code is either the current TOTP code or one of the unused recovery codes.
+func (s Service) VerifyMFA(req VerifyMFARequest) (VerifyMFAResponse, error) {
+	const op = "authentication.service.VerifyMFA"
+
+	if vErr := s.validator.validateVerifyMFA(req); vErr != nil {
+		return VerifyMFAResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage(vErr.Error())
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	key := "mfa-challenge:" + hashToken(req.MFAToken)
+
+	challenge, err := s.getMFAChallenge(key)
+	if errors.Is(err, redis.Nil) {
+		return VerifyMFAResponse{}, richerror.New(op).WithKind(richerror.KindGone).WithMessage("Two-factor challenge has expired")
+	} else if err != nil {
+		return VerifyMFAResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	enrollment, err := s.getTOTP(challenge.UserID)
+	if err != nil || !enrollment.Enabled {
+		_ = redisAdapter.Client().Del(redisAdapter.Context(), key).Err()
+		return VerifyMFAResponse{}, richerror.New(op).WithKind(richerror.KindGone).WithMessage("Two-factor challenge has expired")
+	}
+
+	// the attempt is counted before the code is checked, parallel guesses
+	// cannot get past the limit
+	attempts, err := s.countMFAAttempt(key, s.config.MFAChallengeTTL)
+	if err != nil {
+		return VerifyMFAResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+	if attempts > mfaChallengeAttempts {
+		_ = redisAdapter.Client().Del(redisAdapter.Context(), key).Err()
+		return VerifyMFAResponse{}, richerror.New(op).WithKind(richerror.KindTooManyRequests).WithMessage("Too many attempts")
+	}
+
+	ok, cErr := s.checkSecondFactor(challenge.UserID, enrollment, req.Code)
+	if cErr != nil {
+		return VerifyMFAResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(cErr)
+	}
+	if !ok {
+		if attempts == mfaChallengeAttempts {
+			_ = redisAdapter.Client().Del(redisAdapter.Context(), key).Err()
+			return VerifyMFAResponse{}, richerror.New(op).WithKind(richerror.KindTooManyRequests).WithMessage("Too many attempts")
+		}
+		return VerifyMFAResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage("Invalid two-factor code")
+	}
+
+	// single use, a second caller with the same challenge finds nothing
+	deleted, dErr := redisAdapter.Client().Del(redisAdapter.Context(), key).Result()
+	if dErr != nil {
+		return VerifyMFAResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(dErr)
+	}
+	if deleted == 0 {
+		return VerifyMFAResponse{}, richerror.New(op).WithKind(richerror.KindGone).WithMessage("Two-factor challenge has expired")
+	}
+
+	identity, err := s.userRepo.ByID(challenge.UserID)
+	if err != nil {
+		return VerifyMFAResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	pair, err := s.issueSession(identity, challenge.DeviceID, req.Client)
+	if err != nil {
+		return VerifyMFAResponse{}, richerror.New(op).WithWrapper(err)
+	}
+
+	return VerifyMFAResponse{AccessToken: pair.AccessToken, RefreshToken: pair.RefreshToken, DeviceID: pair.DeviceID, DeviceSecret: pair.DeviceSecret}, nil
+}
+
+// checkSecondFactor accepts a TOTP code newer than the last one used, or a
+// recovery code not used before. Either is consumed by a single atomic
+// command, of two requests racing with the same code only one passes.
+func (s Service) checkSecondFactor(userID string, enrollment totpEnrollment, code string) (bool, error) {
+	redisAdapter := s.otpRepo.Adapter()
+	client, ctx := redisAdapter.Client(), redisAdapter.Context()
+
+	if step, ok := totp.Validate(enrollment.Secret, code, time.Now(), totpSkew); ok {
+		return consumeTOTPStep.Run(ctx, client, []string{"totp-step:" + userID}, step, enrollment.LastStep).Bool()
+	}
+
+	hash := hashRecoveryCode(code)
+	if !slices.Contains(enrollment.RecoveryHashes, hash) {
+		return false, nil
+	}
+	added, err := client.SAdd(ctx, "totp-recovery-used:"+userID, hash).Result()
+	return added == 1, err
+}
+
+// countMFAAttempt counts an attempt at the code guarded by key and returns
+// how many were made, the count lasts until ttl passes without an attempt.
+func (s Service) countMFAAttempt(key string, ttl time.Duration) (int64, error) {
+	redisAdapter := s.otpRepo.Adapter()
+	client, ctx := redisAdapter.Client(), redisAdapter.Context()
+
+	pipe := client.TxPipeline()
+	attempts := pipe.Incr(ctx, key+":attempts")
+	pipe.Expire(ctx, key+":attempts", ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	return attempts.Val(), nil
+}
+
+func (s Service) totpEnabled(userID string) bool {
+	enrollment, err := s.getTOTP(userID)
+	return err == nil && enrollment.Enabled
+}
+
+func (s Service) saveTOTP(userID string, enrollment totpEnrollment) error {
+	data, err := json.Marshal(enrollment)
+	if err != nil {
+		return err
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	return redisAdapter.Client().Set(redisAdapter.Context(), "totp:"+userID, data, 0).Err()
+}
+
+func (s Service) getTOTP(userID string) (totpEnrollment, error) {
+	redisAdapter := s.otpRepo.Adapter()
+	data, err := redisAdapter.Client().Get(redisAdapter.Context(), "totp:"+userID).Bytes()
+	if err != nil {
+		return totpEnrollment{}, err
+	}
+
+	var enrollment totpEnrollment
+	err = json.Unmarshal(data, &enrollment)
+	return enrollment, err
+}
+
+func (s Service) createMFAChallenge(userID, deviceID string) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(mfaChallenge{UserID: userID, DeviceID: deviceID})
+	if err != nil {
+		return "", err
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	err = redisAdapter.Client().Set(redisAdapter.Context(), "mfa-challenge:"+hashToken(token), data, s.config.MFAChallengeTTL).Err()
+	return token, err
+}
+
+func (s Service) getMFAChallenge(key string) (mfaChallenge, error) {
+	redisAdapter := s.otpRepo.Adapter()
+	data, err := redisAdapter.Client().Get(redisAdapter.Context(), key).Bytes()
+	if err != nil {
+		return mfaChallenge{}, err
+	}
+
+	var challenge mfaChallenge
+	err = json.Unmarshal(data, &challenge)
+	return challenge, err
+}
+
+func generateRecoveryCodes() (codes []string, hashes []string, err error) {
+	for range recoveryCodeCount {
+		raw := make([]byte, 5)
+		if _, err = rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(raw))
+		code = code[:4] + "-" + code[4:]
+
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	return hashToken(strings.ToLower(strings.TrimSpace(code)))
+}
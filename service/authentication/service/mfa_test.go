@@ -0,0 +1,86 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hosseinasadian/chat-application/pkg/richerror"
+	"github.com/hosseinasadian/chat-application/pkg/totp"
+)
+
+func enableTestTOTP(t *testing.T, s Service, userID string) string {
+	t.Helper()
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.saveTOTP(userID, totpEnrollment{Secret: secret, Enabled: true, CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	return secret
+}
+
+// wrongTOTPCode returns a code that is not accepted around now.
+func wrongTOTPCode(t *testing.T, secret string) string {
+	t.Helper()
+
+	for _, candidate := range []string{"000000", "111111", "222222"} {
+		if _, ok := totp.Validate(secret, candidate, time.Now(), totpSkew+1); !ok {
+			return candidate
+		}
+	}
+	t.Fatal("no wrong code found")
+	return ""
+}
+
+func TestDisableTOTPLocksOutGuessing(t *testing.T) {
+	s, identity := newTestService(t)
+	secret := enableTestTOTP(t, s, identity.ID)
+	principal := Principal{Kind: PrincipalUser, Subject: identity.ID, Phone: identity.Phone}
+	wrong := wrongTOTPCode(t, secret)
+
+	for i := 0; i < totpDisableAttempts; i++ {
+		_, err := s.DisableTOTP(DisableTOTPRequest{Principal: principal, Code: wrong})
+		var rErr richerror.RichError
+		if !errors.As(err, &rErr) || rErr.Kind() != richerror.KindInvalid {
+			t.Fatalf("attempt %d: err = %v, want an invalid code", i+1, err)
+		}
+	}
+
+	code, err := totp.Code(secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.DisableTOTP(DisableTOTPRequest{Principal: principal, Code: code})
+	var rErr richerror.RichError
+	if !errors.As(err, &rErr) || rErr.Kind() != richerror.KindTooManyRequests {
+		t.Fatalf("valid code after the limit: err = %v, want too many attempts", err)
+	}
+	if !s.totpEnabled(identity.ID) {
+		t.Error("two-factor authentication was disabled past the limit")
+	}
+}
+
+func TestDisableTOTPWithValidCode(t *testing.T) {
+	s, identity := newTestService(t)
+	secret := enableTestTOTP(t, s, identity.ID)
+	principal := Principal{Kind: PrincipalUser, Subject: identity.ID, Phone: identity.Phone}
+
+	if _, err := s.DisableTOTP(DisableTOTPRequest{Principal: principal, Code: wrongTOTPCode(t, secret)}); err == nil {
+		t.Fatal("wrong code accepted")
+	}
+
+	code, err := totp.Code(secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DisableTOTP(DisableTOTPRequest{Principal: principal, Code: code}); err != nil {
+		t.Fatalf("valid code: %v", err)
+	}
+	if s.totpEnabled(identity.ID) {
+		t.Error("two-factor authentication still enabled")
+	}
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// ownerKeyPrefixes are the long lived keys named after the account owner,
+// once its phone number and now its user id. Short lived state such as OTPs
+// and MFA challenges is left to expire, sessions are moved when they are next
+// refreshed.
+var ownerKeyPrefixes = []string{
+	"totp:",
+	"totp-step:",
+	"totp-recovery-used:",
+	"passkey-handle:",
+	"passkey-credentials:",
+	"user-email:",
+	"user-bots:",
+	"user-oauth-clients:",
+	"security-events:",
+	"oauth-consent:",
+}
+
+// ownerValuePrefixes are keys whose value is the account owner.
+var ownerValuePrefixes = []string{"passkey-owner:", "email:"}
+
+// ownerRecordPrefixes are keys holding JSON with an owner_id.
+var ownerRecordPrefixes = []string{"bot:", "oauth-client:"}
+
+type MigrationResult struct {
+	Renamed   int `json:"renamed"`
+	Rewritten int `json:"rewritten"`
+	Conflicts int `json:"conflicts"`
+}
+
+// MigratePhoneKeys rewrites data stored under phone numbers written before
+// numbers were normalized to E.164. It is safe to run more than once; keys
+// whose normalized name is already taken are counted as conflicts and left
+// alone.
+func (s Service) MigratePhoneKeys() (MigrationResult, error) {
+	return s.migrateOwners(func(owner string) (string, error) {
+		return s.normalizePhone(owner), nil
+	})
+}
+
+// MigrateUserIDs moves data stored under phone numbers to the user id of the
+// number, assigning ids to numbers that have none yet. Run it after
+// MigratePhoneKeys so every number is found under its E.164 form.
+func (s Service) MigrateUserIDs() (MigrationResult, error) {
+	return s.migrateOwners(func(owner string) (string, error) {
+		if uuid.Validate(owner) == nil {
+			return owner, nil
+		}
+
+		normalized, err := s.phones.Normalize(owner)
+		if err != nil {
+			// not a phone number, nothing this migration knows how to move
+			return owner, nil
+		}
+
+		identity, err := s.userRepo.FindOrCreate(normalized)
+		return identity.ID, err
+	})
+}
+
+// migrateOwners renames and rewrites every owner reference through convert,
+// leaving the ones it returns unchanged alone.
+func (s Service) migrateOwners(convert func(owner string) (string, error)) (MigrationResult, error) {
+	var result MigrationResult
+	redisAdapter := s.otpRepo.Adapter()
+	client, ctx := redisAdapter.Client(), redisAdapter.Context()
+
+	for _, prefix := range ownerKeyPrefixes {
+		err := s.scanKeys(prefix, func(key string) error {
+			rest := strings.TrimPrefix(key, prefix)
+			raw, suffix, _ := strings.Cut(rest, ":")
+			converted, err := convert(raw)
+			if err != nil || converted == raw {
+				return err
+			}
+
+			target := prefix + converted
+			if suffix != "" {
+				target += ":" + suffix
+			}
+			renamed, err := client.RenameNX(ctx, key, target).Result()
+			if err != nil {
+				return err
+			}
+			if renamed {
+				result.Renamed++
+			} else {
+				result.Conflicts++
+			}
+			return nil
+		})
+		if err != nil {
+			return result, err
+		}
+	}
+
+	for _, prefix := range ownerValuePrefixes {
+		err := s.scanKeys(prefix, func(key string) error {
+			raw, err := client.Get(ctx, key).Result()
+			if err != nil {
+				return ignoreNil(err)
+			}
+			converted, err := convert(raw)
+			if err != nil || converted == raw {
+				return err
+			}
+
+			result.Rewritten++
+			return client.Set(ctx, key, converted, redis.KeepTTL).Err()
+		})
+		if err != nil {
+			return result, err
+		}
+	}
+
+	for _, prefix := range ownerRecordPrefixes {
+		err := s.scanKeys(prefix, func(key string) error {
+			data, err := client.Get(ctx, key).Bytes()
+			if err != nil {
+				return ignoreNil(err)
+			}
+
+			// a generic map keeps fields this code does not know about
+			var record map[string]any
+			if uErr := json.Unmarshal(data, &record); uErr != nil {
+				return nil
+			}
+			raw, _ := record["owner_id"].(string)
+			converted, cErr := convert(raw)
+			if cErr != nil || converted == raw {
+				return cErr
+			}
+			record["owner_id"] = converted
+
+			updated, mErr := json.Marshal(record)
+			if mErr != nil {
+				return mErr
+			}
+			result.Rewritten++
+			return client.Set(ctx, key, updated, redis.KeepTTL).Err()
+		})
+		if err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+func (s Service) scanKeys(prefix string, fn func(key string) error) error {
+	redisAdapter := s.otpRepo.Adapter()
+	iter := redisAdapter.Client().Scan(redisAdapter.Context(), 0, prefix+"*", 500).Iterator()
+	for iter.Next(redisAdapter.Context()) {
+		if err := fn(iter.Val()); err != nil {
+			return err
+		}
+	}
+
+	return iter.Err()
+}
+
+func ignoreNil(err error) error {
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+
+	return err
+}
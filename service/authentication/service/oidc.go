@@ -0,0 +1,576 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/hosseinasadian/chat-application/pkg/richerror"
+	"github.com/hosseinasadian/chat-application/service/authentication/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	ScopeOpenID        = "openid"
+	ScopePhone         = "phone"
+	ScopeOfflineAccess = "offline_access"
+)
+
+var OIDCScopes = []string{ScopeOpenID, ScopePhone, ScopeOfflineAccess}
+
+// OAuth error codes from RFC 6749, they are returned as the error message so
+// clients receive them in the "error" field.
+const (
+	OAuthErrorInvalidRequest          = "invalid_request"
+	OAuthErrorInvalidClient           = "invalid_client"
+	OAuthErrorInvalidGrant            = "invalid_grant"
+	OAuthErrorInvalidScope            = "invalid_scope"
+	OAuthErrorAccessDenied            = "access_denied"
+	OAuthErrorUnsupportedGrantType    = "unsupported_grant_type"
+	OAuthErrorUnsupportedResponseType = "unsupported_response_type"
+	OAuthErrorInsufficientScope       = "insufficient_scope"
+)
+
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+)
+
+const codeChallengeMethodS256 = "S256"
+
+type OIDCConfig struct {
+	Issuer         string        `koanf:"issuer"`
+	ConsentURL     string        `koanf:"consent_url"`
+	SigningKeyFile string        `koanf:"signing_key_file"`
+	RequestTTL     time.Duration `koanf:"request_ttl"`
+	CodeTTL        time.Duration `koanf:"code_ttl"`
+	IDTokenTTL     time.Duration `koanf:"id_token_ttl"`
+}
+
+// oidcSigner signs ID tokens, its public half is published at the JWKS
+// endpoint under KeyID.
+type oidcSigner struct {
+	key   *rsa.PrivateKey
+	keyID string
+}
+
+// authorizationRequest is an authorize call waiting for the user to log in
+// and consent.
+type authorizationRequest struct {
+	ClientID      string `json:"client_id"`
+	RedirectURI   string `json:"redirect_uri"`
+	Scope         string `json:"scope"`
+	State         string `json:"state"`
+	Nonce         string `json:"nonce"`
+	CodeChallenge string `json:"code_challenge"`
+}
+
+type authorizationCode struct {
+	authorizationRequest
+	UserID string `json:"user_id"`
+	Phone  string `json:"phone"`
+}
+
+type IDTokenClaims struct {
+	jwt.RegisteredClaims
+	AuthorizedParty     string `json:"azp"`
+	Nonce               string `json:"nonce,omitempty"`
+	AccessTokenHash     string `json:"at_hash,omitempty"`
+	PhoneNumber         string `json:"phone_number,omitempty"`
+	PhoneNumberVerified bool   `json:"phone_number_verified,omitempty"`
+}
+
+// newOIDCSigner loads the RSA key used for ID tokens. Without a key file a
+// fresh key is generated, which is only fit for development since tokens do
+// not survive a restart.
+func newOIDCSigner(keyFile string) (*oidcSigner, error) {
+	var key *rsa.PrivateKey
+	if keyFile == "" {
+		generated, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		key = generated
+	} else {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("signing key file holds no PEM block")
+		}
+
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		rsaKey, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("signing key is not an RSA key")
+		}
+		key = rsaKey
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(der)
+
+	return &oidcSigner{key: key, keyID: base64.RawURLEncoding.EncodeToString(sum[:12])}, nil
+}
+
+func (s Service) Discovery() (DiscoveryResponse, error) {
+	const op = "authentication.service.Discovery"
+
+	if err := s.oidcAvailable(op); err != nil {
+		return DiscoveryResponse{}, err
+	}
+
+	issuer := strings.TrimSuffix(s.config.OIDC.Issuer, "/")
+	return DiscoveryResponse{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/oauth/authorize",
+		TokenEndpoint:                     issuer + "/oauth/token",
+		UserInfoEndpoint:                  issuer + "/oauth/userinfo",
+		JWKSURI:                           issuer + "/oauth/jwks",
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{GrantTypeAuthorizationCode, GrantTypeRefreshToken},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{jwt.SigningMethodRS256.Alg()},
+		ScopesSupported:                   OIDCScopes,
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{codeChallengeMethodS256},
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "nonce", "azp", "at_hash", "phone_number", "phone_number_verified"},
+	}, nil
+}
+
+func (s Service) JWKS() (JWKSResponse, error) {
+	const op = "authentication.service.JWKS"
+
+	if err := s.oidcAvailable(op); err != nil {
+		return JWKSResponse{}, err
+	}
+
+	public := s.oidcSigner.key.PublicKey
+	return JWKSResponse{Keys: []JWK{{
+		KeyType:   "RSA",
+		Use:       "sig",
+		Algorithm: jwt.SigningMethodRS256.Alg(),
+		KeyID:     s.oidcSigner.keyID,
+		Modulus:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+		Exponent:  base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+	}}}, nil
+}
+
+// Authorize validates an authorization request and parks it until the user
+// consents. Errors about the client or redirect uri are returned, every other
+// error is reported to the client through its redirect uri as RFC 6749 says.
+func (s Service) Authorize(req AuthorizeRequest) (AuthorizeResponse, error) {
+	const op = "authentication.service.Authorize"
+
+	if err := s.oidcAvailable(op); err != nil {
+		return AuthorizeResponse{}, err
+	}
+
+	client, err := s.getOAuthClient(req.ClientID)
+	if err != nil {
+		return AuthorizeResponse{}, richerror.New(op).WithKind(richerror.KindBadRequest).WithMessage(OAuthErrorInvalidClient)
+	}
+	if !client.allowsRedirect(req.RedirectURI) {
+		return AuthorizeResponse{}, richerror.New(op).WithKind(richerror.KindBadRequest).WithMessage(OAuthErrorInvalidRequest)
+	}
+
+	reject := func(code, description string) (AuthorizeResponse, error) {
+		return AuthorizeResponse{RedirectTo: redirectWithParams(req.RedirectURI, map[string]string{
+			"error":             code,
+			"error_description": description,
+			"state":             req.State,
+		})}, nil
+	}
+
+	if req.ResponseType != "code" {
+		return reject(OAuthErrorUnsupportedResponseType, "Only the code response type is supported")
+	}
+
+	scopes := strings.Fields(req.Scope)
+	if !slices.Contains(scopes, ScopeOpenID) {
+		return reject(OAuthErrorInvalidScope, "The openid scope is required")
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(OIDCScopes, scope) {
+			return reject(OAuthErrorInvalidScope, "Unknown scope "+scope)
+		}
+	}
+
+	if vErr := s.validator.validateAuthorize(req); vErr != nil {
+		return reject(OAuthErrorInvalidRequest, vErr.Error())
+	}
+
+	requestID, err := randomToken()
+	if err != nil {
+		return AuthorizeResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	authReq := authorizationRequest{
+		ClientID:      client.ID,
+		RedirectURI:   req.RedirectURI,
+		Scope:         strings.Join(scopes, " "),
+		State:         req.State,
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+	}
+	if sErr := s.saveJSON("oauth-request:"+hashToken(requestID), authReq, s.config.OIDC.RequestTTL); sErr != nil {
+		return AuthorizeResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(sErr)
+	}
+
+	return AuthorizeResponse{RedirectTo: redirectWithParams(s.config.OIDC.ConsentURL, map[string]string{
+		"request_id": requestID,
+	})}, nil
+}
+
+// GetConsent describes a pending authorization request to the logged in user
+// so the consent page can show who is asking for what.
+func (s Service) GetConsent(req GetConsentRequest) (GetConsentResponse, error) {
+	const op = "authentication.service.GetConsent"
+
+	if err := s.oidcAvailable(op); err != nil {
+		return GetConsentResponse{}, err
+	}
+	if !req.Principal.IsUser() {
+		return GetConsentResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Only users can consent")
+	}
+
+	var authReq authorizationRequest
+	err := s.loadJSON("oauth-request:"+hashToken(req.RequestID), &authReq)
+	if errors.Is(err, redis.Nil) {
+		return GetConsentResponse{}, richerror.New(op).WithKind(richerror.KindGone).WithMessage("Authorization request has expired")
+	} else if err != nil {
+		return GetConsentResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	client, err := s.getOAuthClient(authReq.ClientID)
+	if err != nil {
+		return GetConsentResponse{}, richerror.New(op).WithKind(richerror.KindGone).WithMessage("Authorization request has expired")
+	}
+
+	scopes := strings.Fields(authReq.Scope)
+	redisAdapter := s.otpRepo.Adapter()
+	granted, err := redisAdapter.Client().SMembers(redisAdapter.Context(), consentKey(req.Principal.Subject, client.ID)).Result()
+	if err != nil {
+		return GetConsentResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	return GetConsentResponse{
+		ClientID:   client.ID,
+		ClientName: client.Name,
+		Scopes:     scopes,
+		Granted:    containsAll(granted, scopes),
+	}, nil
+}
+
+// DecideConsent finishes an authorization request with the user's answer and
+// returns where the browser must be sent next.
+func (s Service) DecideConsent(req DecideConsentRequest) (DecideConsentResponse, error) {
+	const op = "authentication.service.DecideConsent"
+
+	if err := s.oidcAvailable(op); err != nil {
+		return DecideConsentResponse{}, err
+	}
+	if !req.Principal.IsUser() {
+		return DecideConsentResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Only users can consent")
+	}
+
+	var authReq authorizationRequest
+	err := s.takeJSON("oauth-request:"+hashToken(req.RequestID), &authReq)
+	if errors.Is(err, redis.Nil) {
+		return DecideConsentResponse{}, richerror.New(op).WithKind(richerror.KindGone).WithMessage("Authorization request has expired")
+	} else if err != nil {
+		return DecideConsentResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	if !req.Approve {
+		return DecideConsentResponse{RedirectTo: redirectWithParams(authReq.RedirectURI, map[string]string{
+			"error":             OAuthErrorAccessDenied,
+			"error_description": "The user denied the request",
+			"state":             authReq.State,
+		})}, nil
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	scopes := strings.Fields(authReq.Scope)
+	if aErr := redisAdapter.Client().SAdd(redisAdapter.Context(), consentKey(req.Principal.Subject, authReq.ClientID), scopes).Err(); aErr != nil {
+		return DecideConsentResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(aErr)
+	}
+
+	code, err := randomToken()
+	if err != nil {
+		return DecideConsentResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	grant := authorizationCode{authorizationRequest: authReq, UserID: req.Principal.Subject, Phone: req.Principal.Phone}
+	if sErr := s.saveJSON("oauth-code:"+hashToken(code), grant, s.config.OIDC.CodeTTL); sErr != nil {
+		return DecideConsentResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(sErr)
+	}
+
+	return DecideConsentResponse{RedirectTo: redirectWithParams(authReq.RedirectURI, map[string]string{
+		"code":  code,
+		"state": authReq.State,
+	})}, nil
+}
+
+// Token is the OAuth token endpoint. It exchanges an authorization code or a
+// refresh token issued to the calling client.
+func (s Service) Token(req TokenRequest) (TokenResponse, error) {
+	const op = "authentication.service.Token"
+
+	if err := s.oidcAvailable(op); err != nil {
+		return TokenResponse{}, err
+	}
+
+	client, err := s.authenticateOAuthClient(req.ClientID, req.ClientSecret)
+	if err != nil {
+		return TokenResponse{}, richerror.New(op).WithWrapper(err)
+	}
+
+	switch req.GrantType {
+	case GrantTypeAuthorizationCode:
+		return s.exchangeCode(client, req)
+	case GrantTypeRefreshToken:
+		return s.exchangeRefresh(client, req)
+	default:
+		return TokenResponse{}, richerror.New(op).WithKind(richerror.KindBadRequest).WithMessage(OAuthErrorUnsupportedGrantType)
+	}
+}
+
+func (s Service) exchangeCode(client OAuthClient, req TokenRequest) (TokenResponse, error) {
+	const op = "authentication.service.exchangeCode"
+
+	invalidGrant := richerror.New(op).WithKind(richerror.KindBadRequest).WithMessage(OAuthErrorInvalidGrant)
+	codeHash := hashToken(req.Code)
+
+	var grant authorizationCode
+	err := s.takeJSON("oauth-code:"+codeHash, &grant)
+	if errors.Is(err, redis.Nil) {
+		// a code presented twice may have been intercepted, the tokens issued
+		// for its first use are revoked as RFC 6749 section 4.1.2 asks
+		s.revokeCodeFamily(codeHash)
+		return TokenResponse{}, invalidGrant
+	} else if err != nil {
+		return TokenResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	if grant.ClientID != client.ID || grant.RedirectURI != req.RedirectURI || !verifyCodeChallenge(grant.CodeChallenge, req.CodeVerifier) {
+		return TokenResponse{}, invalidGrant
+	}
+
+	family := newTokenFamily(repository.UserIdentity{ID: grant.UserID, Phone: grant.Phone}, uuid.NewString())
+	family.ClientID = client.ID
+	family.Scope = grant.Scope
+
+	redisAdapter := s.otpRepo.Adapter()
+	_ = redisAdapter.Client().Set(redisAdapter.Context(), "oauth-code-used:"+codeHash, family.ID, s.config.RefreshTokenTTL).Err()
+
+	pair, err := s.startFamily(family)
+	if err != nil {
+		return TokenResponse{}, richerror.New(op).WithWrapper(err)
+	}
+
+	idToken, err := s.issueIDToken(client.ID, grant.UserID, grant.Phone, grant.Scope, grant.Nonce, pair.AccessToken)
+	if err != nil {
+		return TokenResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	res := TokenResponse{
+		AccessToken: pair.AccessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(s.config.AccessTokenTTL.Seconds()),
+		IDToken:     idToken,
+		Scope:       grant.Scope,
+	}
+	if slices.Contains(strings.Fields(grant.Scope), ScopeOfflineAccess) {
+		res.RefreshToken = pair.RefreshToken
+	}
+
+	return res, nil
+}
+
+func (s Service) exchangeRefresh(client OAuthClient, req TokenRequest) (TokenResponse, error) {
+	const op = "authentication.service.exchangeRefresh"
+
+	refreshed, err := s.RefreshToken(RefreshRequest{RefreshToken: req.RefreshToken, ClientID: client.ID, Client: req.Client})
+	if err != nil {
+		var re richerror.RichError
+		if errors.As(err, &re) && re.Kind() == richerror.KindUnexpected {
+			return TokenResponse{}, richerror.New(op).WithWrapper(err)
+		}
+		return TokenResponse{}, richerror.New(op).WithKind(richerror.KindBadRequest).WithMessage(OAuthErrorInvalidGrant)
+	}
+
+	return TokenResponse{
+		AccessToken:  refreshed.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.config.AccessTokenTTL.Seconds()),
+		RefreshToken: refreshed.RefreshToken,
+	}, nil
+}
+
+func (s Service) UserInfo(req UserInfoRequest) (UserInfoResponse, error) {
+	const op = "authentication.service.UserInfo"
+
+	if req.Principal.Kind != PrincipalDelegated || !req.Principal.HasScope(ScopeOpenID) {
+		return UserInfoResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage(OAuthErrorInsufficientScope)
+	}
+
+	res := UserInfoResponse{Subject: req.Principal.Subject}
+	if req.Principal.HasScope(ScopePhone) {
+		res.PhoneNumber = req.Principal.Phone
+		res.PhoneNumberVerified = true
+	}
+
+	return res, nil
+}
+
+func (s Service) issueIDToken(clientID, userID, phone, scope, nonce, accessToken string) (string, error) {
+	now := time.Now()
+	claims := IDTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    strings.TrimSuffix(s.config.OIDC.Issuer, "/"),
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{clientID},
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.config.OIDC.IDTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+		AuthorizedParty: clientID,
+		Nonce:           nonce,
+		AccessTokenHash: leftHalfHash(accessToken),
+	}
+	if slices.Contains(strings.Fields(scope), ScopePhone) {
+		claims.PhoneNumber = phone
+		claims.PhoneNumberVerified = true
+	}
+
+	t := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	t.Header["kid"] = s.oidcSigner.keyID
+	return t.SignedString(s.oidcSigner.key)
+}
+
+func (s Service) revokeCodeFamily(codeHash string) {
+	redisAdapter := s.otpRepo.Adapter()
+	familyID, err := redisAdapter.Client().Get(redisAdapter.Context(), "oauth-code-used:"+codeHash).Result()
+	if err != nil {
+		return
+	}
+
+	family, err := s.getFamily(familyID)
+	if err != nil {
+		return
+	}
+	s.revokeFamily(family, "authorization code reuse")
+}
+
+func (s Service) oidcAvailable(op richerror.Operation) error {
+	if s.oidcSigner == nil {
+		return richerror.New(op).WithKind(richerror.KindNotFound).WithMessage("OpenID Connect is not enabled")
+	}
+
+	return nil
+}
+
+func (s Service) saveJSON(key string, value any, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	return redisAdapter.Client().Set(redisAdapter.Context(), key, data, ttl).Err()
+}
+
+func (s Service) loadJSON(key string, value any) error {
+	redisAdapter := s.otpRepo.Adapter()
+	data, err := redisAdapter.Client().Get(redisAdapter.Context(), key).Bytes()
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, value)
+}
+
+// takeJSON loads and deletes key in one step, so it succeeds once per key.
+func (s Service) takeJSON(key string, value any) error {
+	redisAdapter := s.otpRepo.Adapter()
+	data, err := redisAdapter.Client().GetDel(redisAdapter.Context(), key).Bytes()
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, value)
+}
+
+func consentKey(userID, clientID string) string {
+	return fmt.Sprintf("oauth-consent:%s:%s", userID, clientID)
+}
+
+func verifyCodeChallenge(challenge, verifier string) bool {
+	if challenge == "" || verifier == "" {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// leftHalfHash is the at_hash of an RS256 ID token, the left half of the
+// SHA-256 of the access token.
+func leftHalfHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
+
+func redirectWithParams(target string, params map[string]string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+
+	query := u.Query()
+	for key, value := range params {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+func containsAll(have, want []string) bool {
+	for _, item := range want {
+		if !slices.Contains(have, item) {
+			return false
+		}
+	}
+
+	return true
+}
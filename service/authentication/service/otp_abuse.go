@@ -0,0 +1,320 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hosseinasadian/chat-application/pkg/pow"
+	"github.com/hosseinasadian/chat-application/pkg/richerror"
+)
+
+// OtpAbuseConfig screens SendOtp against SMS pumping, where codes are
+// requested for numbers whose carrier pays the attacker for every text.
+type OtpAbuseConfig struct {
+	// DeniedNumbers, DeniedPrefixes and DeniedCountries are never sent a code.
+	// Prefixes are the start of a number in E.164 form such as "+88213",
+	// countries are ISO 3166 codes. Unlike phone.denied_regions a denied
+	// country keeps its accounts, they only cannot log in by SMS.
+	DeniedNumbers   []string `koanf:"denied_numbers"`
+	DeniedPrefixes  []string `koanf:"denied_prefixes"`
+	DeniedCountries []string `koanf:"denied_countries"`
+	// DeniedNetworks are CIDR ranges whose callers may not request codes.
+	DeniedNetworks []string `koanf:"denied_networks"`
+
+	// PrefixDigits is how many digits of a number, country code included, make
+	// up the prefix counted against PrefixLimit. Pumping walks through the
+	// numbers of one range so their prefix stands out.
+	PrefixDigits int `koanf:"prefix_digits"`
+	PrefixLimit  int `koanf:"prefix_limit"`
+	// IPv4SubnetBits and IPv6SubnetBits size the subnet of the caller counted
+	// against SubnetLimit.
+	IPv4SubnetBits int `koanf:"ipv4_subnet_bits"`
+	IPv6SubnetBits int `koanf:"ipv6_subnet_bits"`
+	SubnetLimit    int `koanf:"subnet_limit"`
+	// Window is the period the limits count requests over. A limit of zero
+	// turns its check off.
+	Window time.Duration `koanf:"window"`
+
+	Challenge StepUpConfig `koanf:"challenge"`
+}
+
+// StepUpConfig picks what a caller over the velocity limits has to pass
+// before a code is sent.
+type StepUpConfig struct {
+	// Driver is "pow" for a proof of work, empty refuses requests over the
+	// limits outright.
+	Driver     string        `koanf:"driver"`
+	Secret     string        `koanf:"secret"`
+	Difficulty int           `koanf:"difficulty"`
+	TTL        time.Duration `koanf:"ttl"`
+}
+
+const StepUpProofOfWork = "pow"
+
+// StepUpChallenge is handed to the client, Data is specific to Type.
+type StepUpChallenge struct {
+	Type string            `json:"type"`
+	Data map[string]string `json:"data"`
+}
+
+// StepUpChallenger issues and checks challenges such as a proof of work or a
+// CAPTCHA. Challenges are issued for a phone number and only pass for it.
+type StepUpChallenger interface {
+	Challenge(phone string, client ClientInfo) (StepUpChallenge, error)
+	Verify(phone string, client ClientInfo, response string) (bool, error)
+}
+
+type otpAbuse struct {
+	numbers    map[string]bool
+	prefixes   []string
+	countries  map[string]bool
+	networks   []netip.Prefix
+	challenger StepUpChallenger
+}
+
+func newOtpAbuse(config *OtpAbuseConfig, normalize func(string) string) otpAbuse {
+	if config.PrefixDigits <= 0 {
+		config.PrefixDigits = 7
+	}
+	if config.IPv4SubnetBits <= 0 || config.IPv4SubnetBits > 32 {
+		config.IPv4SubnetBits = 24
+	}
+	if config.IPv6SubnetBits <= 0 || config.IPv6SubnetBits > 128 {
+		config.IPv6SubnetBits = 48
+	}
+	if config.Window <= 0 {
+		config.Window = time.Hour
+	}
+	if config.Challenge.Difficulty <= 0 {
+		config.Challenge.Difficulty = 20
+	}
+	if config.Challenge.TTL <= 0 {
+		config.Challenge.TTL = 5 * time.Minute
+	}
+
+	abuse := otpAbuse{
+		numbers:   make(map[string]bool, len(config.DeniedNumbers)),
+		countries: make(map[string]bool, len(config.DeniedCountries)),
+	}
+	for _, number := range config.DeniedNumbers {
+		abuse.numbers[normalize(number)] = true
+	}
+	for _, prefix := range config.DeniedPrefixes {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			abuse.prefixes = append(abuse.prefixes, prefix)
+		}
+	}
+	for _, country := range config.DeniedCountries {
+		abuse.countries[strings.ToUpper(strings.TrimSpace(country))] = true
+	}
+	for _, network := range config.DeniedNetworks {
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(network))
+		if err != nil {
+			log.Printf("otp abuse: ignoring denied network %q: %v\n", network, err)
+			continue
+		}
+		abuse.networks = append(abuse.networks, prefix.Masked())
+	}
+
+	switch config.Challenge.Driver {
+	case "":
+	case StepUpProofOfWork:
+		if config.Challenge.Secret == "" {
+			log.Println("otp abuse: proof of work disabled, no secret configured")
+			break
+		}
+		abuse.challenger = powChallenger{
+			issuer:     pow.NewIssuer(config.Challenge.Secret),
+			difficulty: config.Challenge.Difficulty,
+			ttl:        config.Challenge.TTL,
+		}
+	default:
+		log.Printf("otp abuse: unknown challenge driver %q\n", config.Challenge.Driver)
+	}
+
+	return abuse
+}
+
+// deniedReason tells why phone or the caller may not be sent a code, empty
+// when neither is denied.
+func (a otpAbuse) deniedReason(phone, region string, ip netip.Addr) string {
+	if a.numbers[phone] {
+		return "denied_number"
+	}
+	for _, prefix := range a.prefixes {
+		if strings.HasPrefix(phone, prefix) {
+			return "denied_prefix"
+		}
+	}
+	if region != "" && a.countries[region] {
+		return "denied_country"
+	}
+	if ip.IsValid() {
+		for _, network := range a.networks {
+			if network.Contains(ip) {
+				return "denied_network"
+			}
+		}
+	}
+
+	return ""
+}
+
+// screenOtpRequest decides whether a code may be sent for req. Requests over
+// the velocity limits are answered with a challenge, which is not an error,
+// so callers send the code only when the response is empty and err is nil.
+func (s Service) screenOtpRequest(op richerror.Operation, req SendOtpRequest) (SendOtpResponse, error) {
+	config := s.config.OtpAbuse
+	ip, _ := netip.ParseAddr(req.Client.IP)
+	ip = ip.Unmap()
+
+	blocked := SecurityEvent{
+		Type:       SecurityEventOtpBlocked,
+		Phone:      req.Phone,
+		ClientInfo: req.Client,
+	}
+
+	if reason := s.otpAbuse.deniedReason(req.Phone, s.phones.Region(req.Phone), ip); reason != "" {
+		blocked.Reason = reason
+		s.emitSecurityEvent(blocked)
+		return SendOtpResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("A code cannot be sent to this number")
+	}
+
+	reason := ""
+	if config.PrefixLimit > 0 {
+		prefix := req.Phone[:min(len(req.Phone), config.PrefixDigits+1)]
+		over, err := s.overVelocity("prefix:"+prefix, config.PrefixLimit)
+		if err != nil {
+			return SendOtpResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+		}
+		if over {
+			reason = "prefix_velocity"
+		}
+	}
+	if config.SubnetLimit > 0 && ip.IsValid() {
+		bits := config.IPv6SubnetBits
+		if ip.Is4() {
+			bits = config.IPv4SubnetBits
+		}
+		subnet, _ := ip.Prefix(bits)
+		over, err := s.overVelocity("subnet:"+subnet.String(), config.SubnetLimit)
+		if err != nil {
+			return SendOtpResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+		}
+		if over && reason == "" {
+			reason = "subnet_velocity"
+		}
+	}
+	if reason == "" {
+		return SendOtpResponse{}, nil
+	}
+
+	challenger := s.otpAbuse.challenger
+	if challenger == nil {
+		blocked.Reason = reason
+		s.emitSecurityEvent(blocked)
+		return SendOtpResponse{}, richerror.New(op).WithKind(richerror.KindTooManyRequests).WithMessage("Too many code requests, try again later")
+	}
+
+	if req.ChallengeResponse == "" {
+		challenge, err := challenger.Challenge(req.Phone, req.Client)
+		if err != nil {
+			return SendOtpResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+		}
+
+		s.emitSecurityEvent(SecurityEvent{
+			Type:       SecurityEventOtpChallenged,
+			Phone:      req.Phone,
+			Reason:     reason,
+			ClientInfo: req.Client,
+		})
+
+		return SendOtpResponse{
+			Message:           "Complete the challenge to receive a code",
+			ChallengeRequired: true,
+			Challenge:         &challenge,
+		}, nil
+	}
+
+	passed, err := challenger.Verify(req.Phone, req.Client, req.ChallengeResponse)
+	if err != nil {
+		return SendOtpResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+	if passed {
+		// a solution is good for a single code
+		redisAdapter := s.otpRepo.Adapter()
+		passed, err = redisAdapter.Client().SetNX(redisAdapter.Context(), "step-up-used:"+hashToken(req.ChallengeResponse), 1, config.Challenge.TTL).Result()
+		if err != nil {
+			return SendOtpResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+		}
+	}
+	if !passed {
+		blocked.Reason = "challenge_failed"
+		s.emitSecurityEvent(blocked)
+		return SendOtpResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage("Challenge response is invalid")
+	}
+
+	return SendOtpResponse{}, nil
+}
+
+// overVelocity counts a request against key and reports whether more than
+// limit were made in the current window.
+func (s Service) overVelocity(key string, limit int) (bool, error) {
+	return s.overLimit("otp-velocity:"+key, limit, s.config.OtpAbuse.Window)
+}
+
+// overLimit counts a request against key and reports whether more than limit
+// were made in the current fixed window. Slots are counted in nanoseconds so
+// windows shorter than a second work too.
+func (s Service) overLimit(key string, limit int, window time.Duration) (bool, error) {
+	slot := time.Now().UnixNano() / int64(window)
+
+	redisAdapter := s.otpRepo.Adapter()
+	client, ctx := redisAdapter.Client(), redisAdapter.Context()
+
+	key = fmt.Sprintf("%s:%d", key, slot)
+	pipe := client.TxPipeline()
+	count := pipe.Incr(ctx, key)
+	pipe.PExpire(ctx, key, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+
+	return count.Val() > int64(limit), nil
+}
+
+// powChallenger asks for a hashcash proof of work. The response is the puzzle
+// and the nonce found for it joined by a colon.
+type powChallenger struct {
+	issuer     pow.Issuer
+	difficulty int
+	ttl        time.Duration
+}
+
+func (c powChallenger) Challenge(phone string, _ ClientInfo) (StepUpChallenge, error) {
+	puzzle, err := c.issuer.Issue(c.difficulty, c.ttl, phone)
+	if err != nil {
+		return StepUpChallenge{}, err
+	}
+
+	return StepUpChallenge{
+		Type: StepUpProofOfWork,
+		Data: map[string]string{
+			"puzzle":     puzzle,
+			"difficulty": strconv.Itoa(c.difficulty),
+		},
+	}, nil
+}
+
+func (c powChallenger) Verify(phone string, _ ClientInfo, response string) (bool, error) {
+	puzzle, nonce, ok := strings.Cut(response, ":")
+	if !ok {
+		return false, nil
+	}
+
+	return c.issuer.Verify(puzzle, nonce, phone) == nil, nil
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOverLimitWithSubSecondWindow(t *testing.T) {
+	s, _ := newTestService(t)
+	window := 500 * time.Millisecond
+
+	over, err := s.overLimit("test-limit", 1, window)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if over {
+		t.Error("first request over the limit")
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	keys, err := redisAdapter.Client().Keys(redisAdapter.Context(), "test-limit:*").Result()
+	if err != nil || len(keys) != 1 {
+		t.Fatalf("counters = %v, %v, want one", keys, err)
+	}
+	ttl := redisAdapter.Client().PTTL(redisAdapter.Context(), keys[0]).Val()
+	if ttl <= 0 || ttl > window {
+		t.Errorf("counter expires in %v, want within %v", ttl, window)
+	}
+}
@@ -1,48 +1,195 @@
 package service
 
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+)
+
 type SendOtpRequest struct {
 	Phone string `json:"phone"`
+	// ChallengeResponse answers the challenge of a previous response, it is
+	// only looked at while the request needs one.
+	ChallengeResponse string     `json:"challenge_response,omitempty"`
+	Client            ClientInfo `json:"-"`
 }
 type SendOtpResponse struct {
-	Message string `json:"message"`
+	Message           string           `json:"message"`
+	ChallengeRequired bool             `json:"challenge_required,omitempty"`
+	Challenge         *StepUpChallenge `json:"challenge,omitempty"`
 }
 
 type VerifyOtpRequest struct {
-	Phone    string `json:"phone"`
-	Otp      string `json:"otp"`
-	DeviceID string `json:"device_id,omitempty"`
+	Phone        string     `json:"phone"`
+	Otp          string     `json:"otp"`
+	DeviceID     string     `json:"device_id,omitempty"`
+	DeviceSecret string     `json:"device_secret,omitempty"`
+	Client       ClientInfo `json:"-"`
 }
 type VerifyOtpResponse struct {
+	AccessToken      string `json:"access_token,omitempty"`
+	RefreshToken     string `json:"refresh_token,omitempty"`
+	DeviceID         string `json:"device_id,omitempty"`
+	DeviceSecret     string `json:"device_secret,omitempty"`
+	MFARequired      bool   `json:"mfa_required,omitempty"`
+	MFAToken         string `json:"mfa_token,omitempty"`
+	ApprovalRequired bool   `json:"approval_required,omitempty"`
+	ApprovalToken    string `json:"approval_token,omitempty"`
+}
+type VerifyMFARequest struct {
+	MFAToken string     `json:"mfa_token"`
+	Code     string     `json:"code"`
+	Client   ClientInfo `json:"-"`
+}
+type VerifyMFAResponse struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
 	DeviceID     string `json:"device_id"`
+	DeviceSecret string `json:"device_secret,omitempty"`
 }
 type RefreshRequest struct {
-	RefreshToken string `json:"refresh_token"`
+	RefreshToken string     `json:"refresh_token"`
+	DeviceSecret string     `json:"device_secret,omitempty"`
+	ClientID     string     `json:"-"`
+	Client       ClientInfo `json:"-"`
 }
 type RefreshResponse struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
 	DeviceID     string `json:"device_id"`
+	// DeviceSecret is only set when a session from before device secrets
+	// gets one.
+	DeviceSecret string `json:"device_secret,omitempty"`
 }
 
 type MeRequest struct {
-	Claims any `json:"claims"`
+	Principal Principal `json:"principal"`
 }
 type MeResponse struct {
-	ID       int    `json:"id"`
+	ID       string `json:"id"`
 	UserName string `json:"username"`
 	Avatar   string `json:"avatar"`
 	Phone    string `json:"phone"`
+	Email    string `json:"email,omitempty"`
+
+	DeletionScheduledFor *time.Time `json:"deletion_scheduled_for,omitempty"`
 }
 
 type LogoutRequest struct {
-	Claims any `json:"claims"`
+	Principal Principal  `json:"principal"`
+	Client    ClientInfo `json:"-"`
 }
 type LogoutResponse struct {
 	Message string `json:"message"`
 }
 
+type EnrollTOTPRequest struct {
+	Principal Principal `json:"-"`
+}
+type EnrollTOTPResponse struct {
+	Secret          string   `json:"secret"`
+	ProvisioningURI string   `json:"provisioning_uri"`
+	QRPayload       string   `json:"qr_payload"`
+	RecoveryCodes   []string `json:"recovery_codes"`
+}
+
+type ConfirmTOTPRequest struct {
+	Principal Principal `json:"-"`
+	Code      string    `json:"code"`
+}
+type ConfirmTOTPResponse struct {
+	Message string `json:"message"`
+}
+
+type DisableTOTPRequest struct {
+	Principal Principal `json:"-"`
+	Code      string    `json:"code"`
+}
+type DisableTOTPResponse struct {
+	Message string `json:"message"`
+}
+
+type ValidateTokenRequest struct {
+	Token string `json:"token"`
+}
+type ValidateTokenResponse struct {
+	Valid     bool      `json:"valid"`
+	Principal Principal `json:"principal"`
+	ExpiresAt int64     `json:"expires_at,omitempty"`
+}
+
+type IntrospectRequest struct {
+	Token         string `json:"token"`
+	TokenTypeHint string `json:"token_type_hint,omitempty"`
+}
+type IntrospectResponse struct {
+	Active    bool     `json:"active"`
+	TokenType string   `json:"token_type,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	DeviceID  string   `json:"did,omitempty"`
+	Scope     string   `json:"scope,omitempty"`
+	JTI       string   `json:"jti,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	OwnerID   string   `json:"owner_id,omitempty"`
+	KeyID     string   `json:"key_id,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  []string `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+}
+
+type CreateBotRequest struct {
+	Principal Principal `json:"-"`
+	Name      string    `json:"name"`
+}
+type CreateBotResponse struct {
+	Bot Bot `json:"bot"`
+}
+
+type ListBotsRequest struct {
+	Principal Principal `json:"-"`
+}
+type ListBotsResponse struct {
+	Bots []Bot `json:"bots"`
+}
+
+type DeleteBotRequest struct {
+	Principal Principal `json:"-"`
+	BotID     string    `json:"bot_id"`
+}
+type DeleteBotResponse struct {
+	Message string `json:"message"`
+}
+
+type CreateAPIKeyRequest struct {
+	Principal Principal `json:"-"`
+	BotID     string    `json:"-"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+}
+type CreateAPIKeyResponse struct {
+	APIKey APIKey `json:"api_key"`
+	Key    string `json:"key"`
+}
+
+type ListAPIKeysRequest struct {
+	Principal Principal `json:"-"`
+	BotID     string    `json:"bot_id"`
+}
+type ListAPIKeysResponse struct {
+	Keys []APIKey `json:"keys"`
+}
+
+type RevokeAPIKeyRequest struct {
+	Principal Principal `json:"-"`
+	BotID     string    `json:"bot_id"`
+	KeyID     string    `json:"key_id"`
+}
+type RevokeAPIKeyResponse struct {
+	Message string `json:"message"`
+}
+
 type ErrorResponse struct {
 	Error   string `json:"error"`
 	Message string `json:"message,omitempty"`
@@ -52,4 +199,447 @@ type TokenPair struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
 	DeviceID     string `json:"device_id"`
+	// DeviceSecret is only set when the device was issued its secret.
+	DeviceSecret string `json:"device_secret,omitempty"`
+}
+
+type BeginPasskeyRegistrationRequest struct {
+	Principal Principal `json:"-"`
+}
+type BeginPasskeyRegistrationResponse struct {
+	Options *protocol.CredentialCreation `json:"options"`
+}
+
+type FinishPasskeyRegistrationRequest struct {
+	Principal  Principal       `json:"-"`
+	Name       string          `json:"name"`
+	Credential json.RawMessage `json:"credential"`
+}
+type FinishPasskeyRegistrationResponse struct {
+	Passkey Passkey `json:"passkey"`
+}
+
+type ListPasskeysRequest struct {
+	Principal Principal `json:"-"`
+}
+type ListPasskeysResponse struct {
+	Passkeys []Passkey `json:"passkeys"`
+}
+
+type DeletePasskeyRequest struct {
+	Principal Principal `json:"-"`
+	PasskeyID string    `json:"passkey_id"`
+}
+type DeletePasskeyResponse struct {
+	Message string `json:"message"`
+}
+
+type BeginPasskeyLoginRequest struct{}
+type BeginPasskeyLoginResponse struct {
+	SessionID string                        `json:"session_id"`
+	Options   *protocol.CredentialAssertion `json:"options"`
+}
+
+type FinishPasskeyLoginRequest struct {
+	SessionID    string          `json:"session_id"`
+	DeviceID     string          `json:"device_id"`
+	DeviceSecret string          `json:"device_secret"`
+	Credential   json.RawMessage `json:"credential"`
+	Client       ClientInfo      `json:"-"`
+}
+type FinishPasskeyLoginResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	DeviceID     string `json:"device_id"`
+	DeviceSecret string `json:"device_secret,omitempty"`
+}
+
+type CreateOAuthClientRequest struct {
+	Principal    Principal `json:"-"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Public       bool      `json:"public"`
+}
+type CreateOAuthClientResponse struct {
+	Client       OAuthClient `json:"client"`
+	ClientSecret string      `json:"client_secret,omitempty"`
+}
+
+type ListOAuthClientsRequest struct {
+	Principal Principal `json:"-"`
+}
+type ListOAuthClientsResponse struct {
+	Clients []OAuthClient `json:"clients"`
+}
+
+type DeleteOAuthClientRequest struct {
+	Principal Principal `json:"-"`
+	ClientID  string    `json:"client_id"`
+}
+type DeleteOAuthClientResponse struct {
+	Message string `json:"message"`
+}
+
+type DiscoveryResponse struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Modulus   string `json:"n"`
+	Exponent  string `json:"e"`
+}
+
+type JWKSResponse struct {
+	Keys []JWK `json:"keys"`
+}
+
+type AuthorizeRequest struct {
+	ResponseType        string `json:"response_type"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	Nonce               string `json:"nonce"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+}
+type AuthorizeResponse struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+type GetConsentRequest struct {
+	Principal Principal `json:"-"`
+	RequestID string    `json:"request_id"`
+}
+type GetConsentResponse struct {
+	ClientID   string   `json:"client_id"`
+	ClientName string   `json:"client_name"`
+	Scopes     []string `json:"scopes"`
+	Granted    bool     `json:"granted"`
+}
+
+type DecideConsentRequest struct {
+	Principal Principal `json:"-"`
+	RequestID string    `json:"request_id"`
+	Approve   bool      `json:"approve"`
+}
+type DecideConsentResponse struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+type TokenRequest struct {
+	GrantType    string     `json:"grant_type"`
+	Code         string     `json:"code"`
+	RedirectURI  string     `json:"redirect_uri"`
+	CodeVerifier string     `json:"code_verifier"`
+	RefreshToken string     `json:"refresh_token"`
+	ClientID     string     `json:"client_id"`
+	ClientSecret string     `json:"client_secret"`
+	Client       ClientInfo `json:"-"`
+}
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+type UserInfoRequest struct {
+	Principal Principal `json:"-"`
+}
+type UserInfoResponse struct {
+	Subject             string `json:"sub"`
+	PhoneNumber         string `json:"phone_number,omitempty"`
+	PhoneNumberVerified bool   `json:"phone_number_verified,omitempty"`
+}
+
+type LinkEmailRequest struct {
+	Principal Principal `json:"-"`
+	Email     string    `json:"email"`
+}
+type LinkEmailResponse struct {
+	Message string `json:"message"`
+}
+
+type VerifyEmailRequest struct {
+	Principal Principal `json:"-"`
+	Code      string    `json:"code"`
+}
+type VerifyEmailResponse struct {
+	Email string `json:"email"`
+}
+
+type UnlinkEmailRequest struct {
+	Principal Principal `json:"-"`
+}
+type UnlinkEmailResponse struct {
+	Message string `json:"message"`
+}
+
+type SendEmailLoginRequest struct {
+	Email string `json:"email"`
+}
+type SendEmailLoginResponse struct {
+	Message string `json:"message"`
+}
+
+type VerifyEmailOtpRequest struct {
+	Email        string     `json:"email"`
+	Otp          string     `json:"otp"`
+	DeviceID     string     `json:"device_id"`
+	DeviceSecret string     `json:"device_secret"`
+	Client       ClientInfo `json:"-"`
+}
+
+type VerifyMagicLinkRequest struct {
+	Token        string     `json:"token"`
+	DeviceID     string     `json:"device_id"`
+	DeviceSecret string     `json:"device_secret"`
+	Client       ClientInfo `json:"-"`
+}
+
+type StartPhoneChangeRequest struct {
+	Principal Principal `json:"-"`
+	NewPhone  string    `json:"new_phone"`
+	VerifyOld bool      `json:"verify_old"`
+	// ChallengeResponse answers the challenge of a previous response, as for
+	// SendOtp.
+	ChallengeResponse string     `json:"challenge_response,omitempty"`
+	Client            ClientInfo `json:"-"`
+}
+type StartPhoneChangeResponse struct {
+	Message           string           `json:"message"`
+	VerifyOld         bool             `json:"verify_old"`
+	ChallengeRequired bool             `json:"challenge_required,omitempty"`
+	Challenge         *StepUpChallenge `json:"challenge,omitempty"`
+}
+
+type ConfirmPhoneChangeRequest struct {
+	Principal      Principal  `json:"-"`
+	NewCode        string     `json:"new_code"`
+	OldCode        string     `json:"old_code"`
+	TOTPCode       string     `json:"totp_code"`
+	NotifyContacts bool       `json:"notify_contacts"`
+	Client         ClientInfo `json:"-"`
+}
+type ConfirmPhoneChangeResponse struct {
+	Phone        string `json:"phone"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	DeviceID     string `json:"device_id"`
+	DeviceSecret string `json:"device_secret,omitempty"`
+}
+
+type DeleteAccountRequest struct {
+	Principal Principal `json:"-"`
+}
+type DeleteAccountResponse struct {
+	Message              string     `json:"message"`
+	DeletionScheduledFor *time.Time `json:"deletion_scheduled_for"`
+}
+
+type CancelAccountDeletionRequest struct {
+	Principal Principal `json:"-"`
+}
+type CancelAccountDeletionResponse struct {
+	Message string `json:"message"`
+}
+
+type RequestAccountExportRequest struct {
+	Principal Principal `json:"-"`
+}
+type GetAccountExportRequest struct {
+	Principal Principal `json:"-"`
+}
+type AccountExportResponse struct {
+	Export AccountExport `json:"export"`
+}
+
+type DownloadAccountExportRequest struct {
+	Principal Principal `json:"-"`
+}
+type DownloadAccountExportResponse struct {
+	Path     string `json:"-"`
+	FileName string `json:"-"`
+}
+
+type ListSecurityEventsRequest struct {
+	Principal Principal `json:"-"`
+	Limit     int       `json:"limit"`
+}
+type ListSecurityEventsResponse struct {
+	Events []SecurityEvent `json:"events"`
+}
+
+type QueryAuditLogRequest struct {
+	Principal Principal `json:"-"`
+	UserID    string    `json:"user_id"`
+	ActorID   string    `json:"actor_id"`
+	Phone     string    `json:"phone"`
+	Type      string    `json:"type"`
+	IP        string    `json:"ip"`
+	DeviceID  string    `json:"device_id"`
+	Since     time.Time `json:"since"`
+	Until     time.Time `json:"until"`
+	Before    string    `json:"before"`
+	Limit     int       `json:"limit"`
+}
+type QueryAuditLogResponse struct {
+	Events []SecurityEvent `json:"events"`
+	Next   string          `json:"next,omitempty"`
+}
+
+type ListDevicesRequest struct {
+	Principal Principal `json:"-"`
+}
+type ListDevicesResponse struct {
+	Devices  []DeviceInfo   `json:"devices"`
+	Settings DeviceSettings `json:"settings"`
+}
+
+type UpdateDeviceRequest struct {
+	Principal Principal `json:"-"`
+	DeviceID  string    `json:"-"`
+	Name      *string   `json:"name"`
+	Trusted   *bool     `json:"trusted"`
+}
+type UpdateDeviceResponse struct {
+	Device Device `json:"device"`
+}
+
+type RemoveDeviceRequest struct {
+	Principal Principal `json:"-"`
+	DeviceID  string    `json:"-"`
+}
+type RemoveDeviceResponse struct {
+	Message string `json:"message"`
+}
+
+type UpdateDeviceSettingsRequest struct {
+	Principal       Principal `json:"-"`
+	RequireApproval bool      `json:"require_approval"`
+}
+type UpdateDeviceSettingsResponse struct {
+	Settings DeviceSettings `json:"settings"`
+}
+
+type DecideDeviceApprovalRequest struct {
+	Principal  Principal `json:"-"`
+	ApprovalID string    `json:"-"`
+	Approve    bool      `json:"approve"`
+}
+type DecideDeviceApprovalResponse struct {
+	Status string `json:"status"`
+}
+
+type CompleteDeviceApprovalRequest struct {
+	ApprovalToken string     `json:"approval_token"`
+	Client        ClientInfo `json:"-"`
+}
+
+type NotificationsRequest struct {
+	Principal Principal `json:"-"`
+}
+
+type ReportUserRequest struct {
+	Principal Principal `json:"-"`
+	UserID    string    `json:"user_id"`
+	Reason    string    `json:"reason"`
+	Details   string    `json:"details"`
+}
+type ReportUserResponse struct {
+	Message string `json:"message"`
+}
+
+type AdminLookupUserRequest struct {
+	Principal Principal `json:"-"`
+	UserID    string    `json:"user_id"`
+	Phone     string    `json:"phone"`
+}
+type AdminLookupUserResponse struct {
+	User AdminUser `json:"user"`
+}
+
+type AdminRevokeSessionsRequest struct {
+	Principal Principal `json:"-"`
+	UserID    string    `json:"-"`
+}
+type AdminRevokeSessionsResponse struct {
+	Message string `json:"message"`
+}
+
+type AdminUnlockLoginRequest struct {
+	Principal Principal `json:"-"`
+	Phone     string    `json:"phone"`
+}
+type AdminUnlockLoginResponse struct {
+	Message string `json:"message"`
+}
+
+type AdminBanPhoneRequest struct {
+	Principal Principal `json:"-"`
+	Phone     string    `json:"phone"`
+	Reason    string    `json:"reason"`
+}
+type AdminBanPhoneResponse struct {
+	Ban PhoneBan `json:"ban"`
+}
+
+type AdminUnbanPhoneRequest struct {
+	Principal Principal `json:"-"`
+	Phone     string    `json:"-"`
+}
+type AdminUnbanPhoneResponse struct {
+	Message string `json:"message"`
+}
+
+type AdminListPhoneBansRequest struct {
+	Principal Principal `json:"-"`
+}
+type AdminListPhoneBansResponse struct {
+	Bans []PhoneBan `json:"bans"`
+}
+
+type AdminListReportsRequest struct {
+	Principal Principal `json:"-"`
+	Before    string    `json:"before"`
+	Limit     int       `json:"limit"`
+}
+type AdminListReportsResponse struct {
+	Reports []ModerationReport `json:"reports"`
+	Next    string             `json:"next,omitempty"`
+}
+
+type AdminSetRoleRequest struct {
+	Principal Principal `json:"-"`
+	UserID    string    `json:"-"`
+	Role      string    `json:"role"`
+}
+type AdminSetRoleResponse struct {
+	Role AdminRole `json:"role"`
+}
+
+type AdminListRolesRequest struct {
+	Principal Principal `json:"-"`
+}
+type AdminListRolesResponse struct {
+	Roles []AdminRole `json:"roles"`
 }
@@ -0,0 +1,342 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/hosseinasadian/chat-application/pkg/richerror"
+	"github.com/hosseinasadian/chat-application/service/authentication/repository"
+)
+
+type WebAuthnConfig struct {
+	RPID            string        `koanf:"rp_id"`
+	RPDisplayName   string        `koanf:"rp_display_name"`
+	RPOrigins       []string      `koanf:"rp_origins"`
+	CeremonyTimeout time.Duration `koanf:"ceremony_timeout"`
+}
+
+type Passkey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// PasskeyRepository stores the WebAuthn credentials of users together with
+// the random user handle authenticators know them by, and the state of
+// ceremonies in progress. See repository.Passkey for the Redis one.
+type PasskeyRepository interface {
+	UserHandle(userID string) ([]byte, error)
+	// UserIDByHandle returns repository.ErrNotFound for an unknown handle.
+	UserIDByHandle(handle []byte) (string, error)
+	Credentials(userID string) ([]repository.PasskeyCredential, error)
+	SaveCredential(userID string, credential repository.PasskeyCredential) error
+	// DeleteCredential returns repository.ErrNotFound when the user has no
+	// such credential.
+	DeleteCredential(userID string, credentialID []byte) error
+	SaveSession(key string, session webauthn.SessionData, ttl time.Duration) error
+	// TakeSession returns the session once and repository.ErrNotFound after.
+	TakeSession(key string) (webauthn.SessionData, error)
+	DeleteAll(userID string) error
+}
+
+type passkeyUser struct {
+	handle      []byte
+	name        string
+	credentials []webauthn.Credential
+}
+
+func (u passkeyUser) WebAuthnID() []byte                         { return u.handle }
+func (u passkeyUser) WebAuthnName() string                       { return u.name }
+func (u passkeyUser) WebAuthnDisplayName() string                { return u.name }
+func (u passkeyUser) WebAuthnCredentials() []webauthn.Credential { return u.credentials }
+
+func newWebAuthn(config WebAuthnConfig) (*webauthn.WebAuthn, error) {
+	timeout := webauthn.TimeoutConfig{Enforce: true, Timeout: config.CeremonyTimeout, TimeoutUVD: config.CeremonyTimeout}
+
+	return webauthn.New(&webauthn.Config{
+		RPID:          config.RPID,
+		RPDisplayName: config.RPDisplayName,
+		RPOrigins:     config.RPOrigins,
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        timeout,
+			Registration: timeout,
+		},
+	})
+}
+
+func (s Service) BeginPasskeyRegistration(req BeginPasskeyRegistrationRequest) (BeginPasskeyRegistrationResponse, error) {
+	const op = "authentication.service.BeginPasskeyRegistration"
+
+	if err := s.passkeysAvailable(op, req.Principal); err != nil {
+		return BeginPasskeyRegistrationResponse{}, err
+	}
+
+	user, err := s.passkeyUser(req.Principal.Subject, req.Principal.Phone)
+	if err != nil {
+		return BeginPasskeyRegistrationResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(user.credentials))
+	for _, credential := range user.credentials {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+
+	creation, session, err := s.webAuthn.BeginRegistration(user,
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+		webauthn.WithExclusions(exclusions),
+	)
+	if err != nil {
+		return BeginPasskeyRegistrationResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	if sErr := s.passkeyRepo.SaveSession("register:"+req.Principal.Subject, *session, s.config.WebAuthn.CeremonyTimeout); sErr != nil {
+		return BeginPasskeyRegistrationResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(sErr)
+	}
+
+	return BeginPasskeyRegistrationResponse{Options: creation}, nil
+}
+
+func (s Service) FinishPasskeyRegistration(req FinishPasskeyRegistrationRequest) (FinishPasskeyRegistrationResponse, error) {
+	const op = "authentication.service.FinishPasskeyRegistration"
+
+	if err := s.passkeysAvailable(op, req.Principal); err != nil {
+		return FinishPasskeyRegistrationResponse{}, err
+	}
+
+	if vErr := s.validator.validateFinishPasskeyRegistration(req); vErr != nil {
+		return FinishPasskeyRegistrationResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage(vErr.Error())
+	}
+
+	userID := req.Principal.Subject
+	session, err := s.passkeyRepo.TakeSession("register:" + userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return FinishPasskeyRegistrationResponse{}, richerror.New(op).WithKind(richerror.KindGone).WithMessage("Passkey registration has expired")
+	} else if err != nil {
+		return FinishPasskeyRegistrationResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(req.Credential)
+	if err != nil {
+		return FinishPasskeyRegistrationResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage("Invalid passkey credential")
+	}
+
+	user, err := s.passkeyUser(userID, req.Principal.Phone)
+	if err != nil {
+		return FinishPasskeyRegistrationResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	credential, err := s.webAuthn.CreateCredential(user, session, parsed)
+	if err != nil {
+		return FinishPasskeyRegistrationResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage("Passkey registration failed")
+	}
+
+	stored := repository.PasskeyCredential{
+		Credential: *credential,
+		Name:       req.Name,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if sErr := s.passkeyRepo.SaveCredential(userID, stored); sErr != nil {
+		return FinishPasskeyRegistrationResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(sErr)
+	}
+
+	return FinishPasskeyRegistrationResponse{Passkey: passkeyFrom(stored)}, nil
+}
+
+func (s Service) ListPasskeys(req ListPasskeysRequest) (ListPasskeysResponse, error) {
+	const op = "authentication.service.ListPasskeys"
+
+	if err := s.passkeysAvailable(op, req.Principal); err != nil {
+		return ListPasskeysResponse{}, err
+	}
+
+	credentials, err := s.passkeyRepo.Credentials(req.Principal.Subject)
+	if err != nil {
+		return ListPasskeysResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	passkeys := make([]Passkey, 0, len(credentials))
+	for _, credential := range credentials {
+		passkeys = append(passkeys, passkeyFrom(credential))
+	}
+
+	return ListPasskeysResponse{Passkeys: passkeys}, nil
+}
+
+func (s Service) DeletePasskey(req DeletePasskeyRequest) (DeletePasskeyResponse, error) {
+	const op = "authentication.service.DeletePasskey"
+
+	if err := s.passkeysAvailable(op, req.Principal); err != nil {
+		return DeletePasskeyResponse{}, err
+	}
+
+	credentialID, err := base64.RawURLEncoding.DecodeString(req.PasskeyID)
+	if err != nil {
+		return DeletePasskeyResponse{}, richerror.New(op).WithKind(richerror.KindNotFound).WithMessage("Passkey not found")
+	}
+
+	dErr := s.passkeyRepo.DeleteCredential(req.Principal.Subject, credentialID)
+	if errors.Is(dErr, repository.ErrNotFound) {
+		return DeletePasskeyResponse{}, richerror.New(op).WithKind(richerror.KindNotFound).WithMessage("Passkey not found")
+	} else if dErr != nil {
+		return DeletePasskeyResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(dErr)
+	}
+
+	return DeletePasskeyResponse{Message: "Passkey deleted"}, nil
+}
+
+// BeginPasskeyLogin starts a discoverable login, the authenticator picks the
+// account so the client does not have to know the phone number.
+func (s Service) BeginPasskeyLogin(req BeginPasskeyLoginRequest) (BeginPasskeyLoginResponse, error) {
+	const op = "authentication.service.BeginPasskeyLogin"
+
+	if s.webAuthn == nil {
+		return BeginPasskeyLoginResponse{}, richerror.New(op).WithKind(richerror.KindNotFound).WithMessage("Passkeys are not enabled")
+	}
+
+	assertion, session, err := s.webAuthn.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		return BeginPasskeyLoginResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	sessionID, err := randomToken()
+	if err != nil {
+		return BeginPasskeyLoginResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	if sErr := s.passkeyRepo.SaveSession("login:"+hashToken(sessionID), *session, s.config.WebAuthn.CeremonyTimeout); sErr != nil {
+		return BeginPasskeyLoginResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(sErr)
+	}
+
+	return BeginPasskeyLoginResponse{SessionID: sessionID, Options: assertion}, nil
+}
+
+// FinishPasskeyLogin verifies the assertion and logs the owner of the passkey
+// in, returning the same token pair VerifyOtp does.
+func (s Service) FinishPasskeyLogin(req FinishPasskeyLoginRequest) (FinishPasskeyLoginResponse, error) {
+	const op = "authentication.service.FinishPasskeyLogin"
+
+	if s.webAuthn == nil {
+		return FinishPasskeyLoginResponse{}, richerror.New(op).WithKind(richerror.KindNotFound).WithMessage("Passkeys are not enabled")
+	}
+
+	if vErr := s.validator.validateFinishPasskeyLogin(req); vErr != nil {
+		return FinishPasskeyLoginResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage(vErr.Error())
+	}
+
+	session, err := s.passkeyRepo.TakeSession("login:" + hashToken(req.SessionID))
+	if errors.Is(err, repository.ErrNotFound) {
+		return FinishPasskeyLoginResponse{}, richerror.New(op).WithKind(richerror.KindGone).WithMessage("Passkey login has expired")
+	} else if err != nil {
+		return FinishPasskeyLoginResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(req.Credential)
+	if err != nil {
+		return FinishPasskeyLoginResponse{}, richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage("Invalid passkey")
+	}
+
+	var identity repository.UserIdentity
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		owner, pErr := s.passkeyRepo.UserIDByHandle(userHandle)
+		if pErr != nil {
+			return nil, pErr
+		}
+		identity, pErr = s.userRepo.ByID(owner)
+		if pErr != nil {
+			return nil, pErr
+		}
+
+		return s.passkeyUser(identity.ID, identity.Phone)
+	}
+
+	credential, err := s.webAuthn.ValidateDiscoverableLogin(handler, session, parsed)
+	if err != nil || identity.ID == "" {
+		return FinishPasskeyLoginResponse{}, richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage("Invalid passkey")
+	}
+
+	// a signature counter that went backwards means the key was cloned
+	if credential.Authenticator.CloneWarning {
+		return FinishPasskeyLoginResponse{}, richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage("Invalid passkey")
+	}
+
+	s.touchPasskey(identity.ID, *credential)
+
+	// a passkey proves an authenticator the user enrolled, so it does not
+	// wait for device approval
+	deviceID := s.provenDevice(identity.ID, req.DeviceID, req.DeviceSecret)
+	pair, err := s.issueSession(identity, deviceID, req.Client)
+	if err != nil {
+		return FinishPasskeyLoginResponse{}, richerror.New(op).WithWrapper(err)
+	}
+
+	return FinishPasskeyLoginResponse{AccessToken: pair.AccessToken, RefreshToken: pair.RefreshToken, DeviceID: pair.DeviceID, DeviceSecret: pair.DeviceSecret}, nil
+}
+
+func (s Service) passkeysAvailable(op richerror.Operation, principal Principal) error {
+	if s.webAuthn == nil {
+		return richerror.New(op).WithKind(richerror.KindNotFound).WithMessage("Passkeys are not enabled")
+	}
+	if !principal.IsUser() {
+		return richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Only users can manage passkeys")
+	}
+
+	return nil
+}
+
+// passkeyUser shows the phone number to the authenticator as the account
+// name, the handle it stores is random.
+func (s Service) passkeyUser(userID, phone string) (passkeyUser, error) {
+	handle, err := s.passkeyRepo.UserHandle(userID)
+	if err != nil {
+		return passkeyUser{}, err
+	}
+
+	stored, err := s.passkeyRepo.Credentials(userID)
+	if err != nil {
+		return passkeyUser{}, err
+	}
+
+	credentials := make([]webauthn.Credential, 0, len(stored))
+	for _, credential := range stored {
+		credentials = append(credentials, credential.Credential)
+	}
+
+	return passkeyUser{handle: handle, name: phone, credentials: credentials}, nil
+}
+
+// touchPasskey stores the new signature counter and the time of use.
+func (s Service) touchPasskey(userID string, credential webauthn.Credential) {
+	stored, err := s.passkeyRepo.Credentials(userID)
+	if err != nil {
+		return
+	}
+
+	for _, existing := range stored {
+		if string(existing.Credential.ID) != string(credential.ID) {
+			continue
+		}
+		existing.Credential = credential
+		existing.LastUsedAt = time.Now().UTC()
+		_ = s.passkeyRepo.SaveCredential(userID, existing)
+		return
+	}
+}
+
+func passkeyFrom(credential repository.PasskeyCredential) Passkey {
+	passkey := Passkey{
+		ID:        base64.RawURLEncoding.EncodeToString(credential.Credential.ID),
+		Name:      credential.Name,
+		CreatedAt: credential.CreatedAt,
+	}
+	if !credential.LastUsedAt.IsZero() {
+		passkey.LastUsedAt = &credential.LastUsedAt
+	}
+
+	return passkey
+}
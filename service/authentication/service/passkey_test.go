@@ -0,0 +1,286 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/hosseinasadian/chat-application/service/authentication/repository"
+)
+
+const (
+	testRPID   = "localhost"
+	testOrigin = "http://localhost:3000"
+)
+
+var b64 = base64.RawURLEncoding
+
+// softAuthenticator is a platform authenticator in software, holding one
+// ES256 credential with "none" attestation.
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	counter      uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentialID := make([]byte, 16)
+	if _, err := rand.Read(credentialID); err != nil {
+		t.Fatal(err)
+	}
+
+	return &softAuthenticator{key: key, credentialID: credentialID}
+}
+
+func (a *softAuthenticator) clientData(t *testing.T, typ string, challenge []byte) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(map[string]string{
+		"type":      typ,
+		"challenge": b64.EncodeToString(challenge),
+		"origin":    testOrigin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+// authData is rpIdHash, the flags user present and verified, the counter and
+// the attested credential when given.
+func (a *softAuthenticator) authData(flags byte, attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.counter)
+	return append(data, attested...)
+}
+
+// create answers the options of BeginPasskeyRegistration.
+func (a *softAuthenticator) create(t *testing.T, challenge, userHandle []byte) json.RawMessage {
+	t.Helper()
+	a.userHandle = userHandle
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: a.key.X.FillBytes(make([]byte, 32)),
+		YCoord: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attested := make([]byte, 16) // zero AAGUID
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.credentialID)))
+	attested = append(attested, a.credentialID...)
+	attested = append(attested, publicKey...)
+
+	attestation, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": a.authData(0x45, attested),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a.credential(t, map[string]string{
+		"clientDataJSON":    b64.EncodeToString(a.clientData(t, "webauthn.create", challenge)),
+		"attestationObject": b64.EncodeToString(attestation),
+	})
+}
+
+// get answers the options of BeginPasskeyLogin with the given counter.
+func (a *softAuthenticator) get(t *testing.T, challenge []byte, counter uint32) json.RawMessage {
+	t.Helper()
+	a.counter = counter
+
+	clientData := a.clientData(t, "webauthn.get", challenge)
+	authData := a.authData(0x05, nil)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a.credential(t, map[string]string{
+		"clientDataJSON":    b64.EncodeToString(clientData),
+		"authenticatorData": b64.EncodeToString(authData),
+		"signature":         b64.EncodeToString(signature),
+		"userHandle":        b64.EncodeToString(a.userHandle),
+	})
+}
+
+func (a *softAuthenticator) credential(t *testing.T, response map[string]string) json.RawMessage {
+	t.Helper()
+
+	data, err := json.Marshal(map[string]any{
+		"id":       b64.EncodeToString(a.credentialID),
+		"rawId":    b64.EncodeToString(a.credentialID),
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func registerPasskey(t *testing.T, s Service, identity repository.UserIdentity, authenticator *softAuthenticator) {
+	t.Helper()
+
+	principal := Principal{Kind: PrincipalUser, Subject: identity.ID, Phone: identity.Phone}
+	begin, err := s.BeginPasskeyRegistration(BeginPasskeyRegistrationRequest{Principal: principal})
+	if err != nil {
+		t.Fatalf("begin registration: %v", err)
+	}
+
+	handle, err := s.passkeyRepo.UserHandle(identity.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.FinishPasskeyRegistration(FinishPasskeyRegistrationRequest{
+		Principal:  principal,
+		Name:       "Test key",
+		Credential: authenticator.create(t, begin.Options.Response.Challenge, handle),
+	})
+	if err != nil {
+		t.Fatalf("finish registration: %v", err)
+	}
+}
+
+func loginWithPasskey(t *testing.T, s Service, authenticator *softAuthenticator, counter uint32) (FinishPasskeyLoginResponse, error) {
+	t.Helper()
+
+	begin, err := s.BeginPasskeyLogin(BeginPasskeyLoginRequest{})
+	if err != nil {
+		t.Fatalf("begin login: %v", err)
+	}
+
+	return s.FinishPasskeyLogin(FinishPasskeyLoginRequest{
+		SessionID:  begin.SessionID,
+		Credential: authenticator.get(t, begin.Options.Response.Challenge, counter),
+	})
+}
+
+func TestPasskeyRegistration(t *testing.T) {
+	s, identity := newTestService(t)
+	authenticator := newSoftAuthenticator(t)
+
+	registerPasskey(t, s, identity, authenticator)
+
+	list, err := s.ListPasskeys(ListPasskeysRequest{Principal: Principal{Kind: PrincipalUser, Subject: identity.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Passkeys) != 1 || list.Passkeys[0].ID != b64.EncodeToString(authenticator.credentialID) {
+		t.Fatalf("passkeys = %+v, want the registered one", list.Passkeys)
+	}
+}
+
+func TestPasskeyRegistrationIsSingleUse(t *testing.T) {
+	s, identity := newTestService(t)
+	authenticator := newSoftAuthenticator(t)
+	principal := Principal{Kind: PrincipalUser, Subject: identity.ID, Phone: identity.Phone}
+
+	begin, err := s.BeginPasskeyRegistration(BeginPasskeyRegistrationRequest{Principal: principal})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle, err := s.passkeyRepo.UserHandle(identity.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential := authenticator.create(t, begin.Options.Response.Challenge, handle)
+
+	req := FinishPasskeyRegistrationRequest{Principal: principal, Name: "Test key", Credential: credential}
+	if _, err := s.FinishPasskeyRegistration(req); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.FinishPasskeyRegistration(req); err == nil {
+		t.Error("registration finished twice")
+	}
+}
+
+func TestPasskeyLogin(t *testing.T) {
+	s, identity := newTestService(t)
+	authenticator := newSoftAuthenticator(t)
+	registerPasskey(t, s, identity, authenticator)
+
+	res, err := loginWithPasskey(t, s, authenticator, 1)
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	claims, err := s.parseClaims(res.AccessToken, TokenTypeAccess)
+	if err != nil {
+		t.Fatalf("access token: %v", err)
+	}
+	if claims.Subject != identity.ID {
+		t.Errorf("subject = %q, want %q", claims.Subject, identity.ID)
+	}
+}
+
+func TestPasskeyLoginRejectsWrongChallenge(t *testing.T) {
+	s, identity := newTestService(t)
+	authenticator := newSoftAuthenticator(t)
+	registerPasskey(t, s, identity, authenticator)
+
+	begin, err := s.BeginPasskeyLogin(BeginPasskeyLoginRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.FinishPasskeyLogin(FinishPasskeyLoginRequest{
+		SessionID:  begin.SessionID,
+		Credential: authenticator.get(t, []byte("not the challenge"), 1),
+	})
+	if err == nil {
+		t.Error("assertion over another challenge accepted")
+	}
+}
+
+func TestPasskeyLoginRejectsCounterRollback(t *testing.T) {
+	s, identity := newTestService(t)
+	authenticator := newSoftAuthenticator(t)
+	registerPasskey(t, s, identity, authenticator)
+
+	if _, err := loginWithPasskey(t, s, authenticator, 5); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	// a clone of the key still has an older counter
+	if _, err := loginWithPasskey(t, s, authenticator, 3); err == nil {
+		t.Error("login with a counter that went backwards accepted")
+	}
+	if _, err := loginWithPasskey(t, s, authenticator, 5); err == nil {
+		t.Error("login with a repeated counter accepted")
+	}
+
+	if _, err := loginWithPasskey(t, s, authenticator, 6); err != nil {
+		t.Errorf("login with a newer counter: %v", err)
+	}
+}
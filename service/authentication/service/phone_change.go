@@ -0,0 +1,237 @@
+package service
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/hosseinasadian/chat-application/pkg/richerror"
+	"github.com/hosseinasadian/chat-application/service/authentication/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+type PhoneChangeConfig struct {
+	CodeTTL  time.Duration `koanf:"code_ttl"`
+	Cooldown time.Duration `koanf:"cooldown"`
+	// UserLimit is how many changes a user may start and NumberLimit how many
+	// may target one new number within LimitWindow, every start sends a text.
+	UserLimit   int           `koanf:"user_limit"`
+	NumberLimit int           `koanf:"number_limit"`
+	LimitWindow time.Duration `koanf:"limit_window"`
+}
+
+const phoneChangeAttempts = 5
+
+// phoneChange is a pending move to NewPhone. OldCodeHash is empty when the
+// user can no longer receive SMS on the old number.
+type phoneChange struct {
+	NewPhone    string `json:"new_phone"`
+	NewCodeHash string `json:"new_code_hash"`
+	OldCodeHash string `json:"old_code_hash,omitempty"`
+	Attempts    int    `json:"attempts"`
+}
+
+// StartPhoneChange sends a code to the new number, and to the current one
+// when VerifyOld is set. Skipping the current number is meant for a lost
+// SIM, accounts with two-factor authentication then confirm with a TOTP code.
+func (s Service) StartPhoneChange(req StartPhoneChangeRequest) (StartPhoneChangeResponse, error) {
+	const op = "authentication.service.StartPhoneChange"
+
+	if !req.Principal.IsUser() {
+		return StartPhoneChangeResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Only users can change their phone number")
+	}
+
+	req.NewPhone = s.normalizePhone(req.NewPhone)
+	if vErr := s.validator.validateStartPhoneChange(req); vErr != nil {
+		return StartPhoneChangeResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage(vErr.Error())
+	}
+
+	identity, err := s.userRepo.ByID(req.Principal.Subject)
+	if err != nil {
+		return StartPhoneChangeResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+	if identity.Phone == req.NewPhone {
+		return StartPhoneChangeResponse{}, richerror.New(op).WithKind(richerror.KindBadRequest).WithMessage("This is already your phone number")
+	}
+	if !identity.PhoneChangedAt.IsZero() && time.Since(identity.PhoneChangedAt) < s.config.PhoneChange.Cooldown {
+		return StartPhoneChangeResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Your phone number was changed recently, try again later")
+	}
+
+	_, err = s.userRepo.IDByPhone(req.NewPhone)
+	if err == nil {
+		return StartPhoneChangeResponse{}, richerror.New(op).WithKind(richerror.KindBadRequest).WithMessage("Phone number is already in use")
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return StartPhoneChangeResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	if bErr := s.checkPhoneBan(op, req.NewPhone); bErr != nil {
+		return StartPhoneChangeResponse{}, bErr
+	}
+
+	// the new number is screened like a login, a challenge is passed back
+	// before the limits below count the request
+	screened, err := s.screenOtpRequest(op, SendOtpRequest{
+		Phone:             req.NewPhone,
+		ChallengeResponse: req.ChallengeResponse,
+		Client:            req.Client,
+	})
+	if err != nil {
+		return StartPhoneChangeResponse{}, err
+	}
+	if screened.ChallengeRequired {
+		return StartPhoneChangeResponse{
+			Message:           screened.Message,
+			VerifyOld:         req.VerifyOld,
+			ChallengeRequired: true,
+			Challenge:         screened.Challenge,
+		}, nil
+	}
+
+	config := s.config.PhoneChange
+	limits := []struct {
+		key   string
+		limit int
+	}{
+		{"phone-change-sends:user:" + identity.ID, config.UserLimit},
+		{"phone-change-sends:number:" + req.NewPhone, config.NumberLimit},
+	}
+	for _, l := range limits {
+		over, lErr := s.overLimit(l.key, l.limit, config.LimitWindow)
+		if lErr != nil {
+			return StartPhoneChangeResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(lErr)
+		}
+		if over {
+			return StartPhoneChangeResponse{}, richerror.New(op).WithKind(richerror.KindTooManyRequests).WithMessage("Too many phone change requests, try again later")
+		}
+	}
+
+	newCode, err := randomDigits(6)
+	if err != nil {
+		return StartPhoneChangeResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+	change := phoneChange{NewPhone: req.NewPhone, NewCodeHash: hashToken(newCode)}
+
+	var oldCode string
+	if req.VerifyOld {
+		oldCode, err = randomDigits(6)
+		if err != nil {
+			return StartPhoneChangeResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+		}
+		change.OldCodeHash = hashToken(oldCode)
+	}
+
+	if sErr := s.saveJSON("phone-change:"+identity.ID, change, s.config.PhoneChange.CodeTTL); sErr != nil {
+		return StartPhoneChangeResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(sErr)
+	}
+
+	if dErr := s.deliverOtp(req.NewPhone, newCode); dErr != nil {
+		return StartPhoneChangeResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithMessage("Failed to send verification code").WithWrapper(dErr)
+	}
+	if req.VerifyOld {
+		if dErr := s.deliverOtp(identity.Phone, oldCode); dErr != nil {
+			return StartPhoneChangeResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithMessage("Failed to send verification code").WithWrapper(dErr)
+		}
+	}
+
+	return StartPhoneChangeResponse{Message: "Verification code sent", VerifyOld: req.VerifyOld}, nil
+}
+
+// ConfirmPhoneChange moves the account to the new number once the codes
+// check out. Every session of the user is revoked, the device confirming the
+// change gets a new token pair.
+func (s Service) ConfirmPhoneChange(req ConfirmPhoneChangeRequest) (ConfirmPhoneChangeResponse, error) {
+	const op = "authentication.service.ConfirmPhoneChange"
+
+	if !req.Principal.IsUser() {
+		return ConfirmPhoneChangeResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Only users can change their phone number")
+	}
+
+	if vErr := s.validator.validateConfirmPhoneChange(req); vErr != nil {
+		return ConfirmPhoneChangeResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage(vErr.Error())
+	}
+
+	userID := req.Principal.Subject
+	key := "phone-change:" + userID
+
+	var change phoneChange
+	err := s.loadJSON(key, &change)
+	if errors.Is(err, redis.Nil) {
+		return ConfirmPhoneChangeResponse{}, richerror.New(op).WithKind(richerror.KindGone).WithMessage("Phone change has expired")
+	} else if err != nil {
+		return ConfirmPhoneChangeResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	ok := subtle.ConstantTimeCompare([]byte(change.NewCodeHash), []byte(hashToken(req.NewCode))) == 1
+	if change.OldCodeHash != "" {
+		ok = subtle.ConstantTimeCompare([]byte(change.OldCodeHash), []byte(hashToken(req.OldCode))) == 1 && ok
+	} else if ok && s.totpEnabled(userID) {
+		enrollment, gErr := s.getTOTP(userID)
+		if gErr != nil {
+			return ConfirmPhoneChangeResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(gErr)
+		}
+		ok, err = s.checkSecondFactor(userID, enrollment, req.TOTPCode)
+		if err != nil {
+			return ConfirmPhoneChangeResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+		}
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	if !ok {
+		change.Attempts++
+		if change.Attempts >= phoneChangeAttempts {
+			_ = redisAdapter.Client().Del(redisAdapter.Context(), key).Err()
+			return ConfirmPhoneChangeResponse{}, richerror.New(op).WithKind(richerror.KindTooManyRequests).WithMessage("Too many attempts")
+		}
+		if data, mErr := json.Marshal(change); mErr == nil {
+			_ = redisAdapter.Client().Set(redisAdapter.Context(), key, data, redis.KeepTTL).Err()
+		}
+		return ConfirmPhoneChangeResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage("Invalid verification code")
+	}
+
+	// single use, a second caller with the same codes finds nothing
+	deleted, err := redisAdapter.Client().Del(redisAdapter.Context(), key).Result()
+	if err != nil {
+		return ConfirmPhoneChangeResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+	if deleted == 0 {
+		return ConfirmPhoneChangeResponse{}, richerror.New(op).WithKind(richerror.KindGone).WithMessage("Phone change has expired")
+	}
+
+	identity, err := s.userRepo.ChangePhone(userID, change.NewPhone)
+	if errors.Is(err, repository.ErrPhoneTaken) {
+		return ConfirmPhoneChangeResponse{}, richerror.New(op).WithKind(richerror.KindBadRequest).WithMessage("Phone number is already in use")
+	} else if err != nil {
+		return ConfirmPhoneChangeResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	// sessions of a legacy token are still stored under the old number
+	s.revokeAllFamilies(SecurityEventPhoneChanged, userID, req.Principal.Phone)
+	s.emitSecurityEvent(SecurityEvent{
+		Type:     SecurityEventPhoneChanged,
+		UserID:   userID,
+		DeviceID: req.Principal.DeviceID,
+	})
+	if pErr := s.publishAccountEvent(AccountEvent{
+		Type:           AccountEventPhoneChanged,
+		UserID:         userID,
+		Phone:          identity.Phone,
+		NotifyContacts: req.NotifyContacts,
+	}); pErr != nil {
+		log.Printf("phone change of %s not recorded in account-events: %v\n", userID, pErr)
+	}
+
+	pair, err := s.issueSession(identity, req.Principal.DeviceID, req.Client)
+	if err != nil {
+		return ConfirmPhoneChangeResponse{}, richerror.New(op).WithWrapper(err)
+	}
+
+	return ConfirmPhoneChangeResponse{
+		Phone:        identity.Phone,
+		AccessToken:  pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		DeviceID:     pair.DeviceID,
+		DeviceSecret: pair.DeviceSecret,
+	}, nil
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hosseinasadian/chat-application/pkg/richerror"
+	"github.com/hosseinasadian/chat-application/service/authentication/repository"
+)
+
+type PrincipalKind string
+
+const (
+	PrincipalUser      PrincipalKind = "user"
+	PrincipalBot       PrincipalKind = "bot"
+	PrincipalDelegated PrincipalKind = "delegated"
+	// PrincipalOperator is someone running the admin commands. It is never
+	// the result of authenticating a request.
+	PrincipalOperator PrincipalKind = "operator"
+)
+
+const (
+	ScopeProfileRead   = "profile:read"
+	ScopeMessagesRead  = "messages:read"
+	ScopeMessagesWrite = "messages:write"
+)
+
+var APIKeyScopes = []string{ScopeProfileRead, ScopeMessagesRead, ScopeMessagesWrite}
+
+// Principal is the authenticated caller of a request, either a user holding
+// an access token, a bot holding an API key or an OAuth client acting for a
+// user with the scopes the user consented to.
+type Principal struct {
+	Kind     PrincipalKind `json:"kind"`
+	Subject  string        `json:"sub"`
+	Phone    string        `json:"phone,omitempty"`
+	DeviceID string        `json:"did,omitempty"`
+	ClientID string        `json:"client_id,omitempty"`
+	OwnerID  string        `json:"owner_id,omitempty"`
+	KeyID    string        `json:"key_id,omitempty"`
+	Scopes   []string      `json:"scopes,omitempty"`
+	Claims   *Claims       `json:"-"`
+}
+
+// HasScope reports whether the principal may act with scope. Users are not
+// restricted by scopes, API keys and OAuth clients are.
+func (p Principal) HasScope(scope string) bool {
+	if p.Kind == PrincipalUser {
+		return true
+	}
+
+	return slices.Contains(p.Scopes, scope)
+}
+
+func (p Principal) IsUser() bool {
+	return p.Kind == PrincipalUser
+}
+
+// OperatorPrincipal is the principal of the admin commands, name is who ran
+// them.
+func OperatorPrincipal(name string) Principal {
+	return Principal{Kind: PrincipalOperator, Subject: name}
+}
+
+// Authenticate resolves an Authorization header to a principal. It accepts
+// "Bearer <access token>" for users and "ApiKey <key>" for bots.
+func (s Service) Authenticate(authHeader string) (Principal, error) {
+	const op = "authentication/service.Authenticate"
+
+	if key, ok := strings.CutPrefix(authHeader, "ApiKey "); ok {
+		return s.authenticateAPIKey(key)
+	}
+
+	claims, pErr := s.ParseToken(authHeader)
+	if pErr != nil {
+		return Principal{}, richerror.New(op).WithWrapper(pErr)
+	}
+
+	if sErr := s.checkSession(op, claims); sErr != nil {
+		return Principal{}, sErr
+	}
+
+	identity, iErr := s.identityOf(claims)
+	if iErr != nil {
+		return Principal{}, richerror.New(op).WithWrapper(iErr)
+	}
+
+	if claims.ClientID != "" {
+		return Principal{
+			Kind:     PrincipalDelegated,
+			Subject:  identity.ID,
+			Phone:    identity.Phone,
+			DeviceID: claims.DeviceID,
+			ClientID: claims.ClientID,
+			Scopes:   strings.Fields(claims.Scope),
+			Claims:   claims,
+		}, nil
+	}
+
+	return Principal{
+		Kind:     PrincipalUser,
+		Subject:  identity.ID,
+		Phone:    identity.Phone,
+		DeviceID: claims.DeviceID,
+		Claims:   claims,
+	}, nil
+}
+
+// identityOf returns the user claims were issued to.
+func (s Service) identityOf(claims *Claims) (repository.UserIdentity, error) {
+	if claims.Phone == "" {
+		return s.legacyIdentity(claims.Subject)
+	}
+
+	return repository.UserIdentity{ID: claims.Subject, Phone: claims.Phone}, nil
+}
+
+// legacyIdentity resolves the phone number that tokens issued before user ids
+// were introduced carry as subject. They are honoured until the configured
+// end of the transition, after which the user has to log in again.
+func (s Service) legacyIdentity(phone string) (repository.UserIdentity, error) {
+	const op = "authentication/service.legacyIdentity"
+
+	if !time.Now().Before(s.config.LegacySubjectUntil) {
+		return repository.UserIdentity{}, richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage(MessageSessionExpired)
+	}
+
+	// only an existing account is resolved, authenticating never signs up
+	phone = s.normalizePhone(phone)
+	id, err := s.userRepo.IDByPhone(phone)
+	if errors.Is(err, repository.ErrNotFound) {
+		return repository.UserIdentity{}, richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage(MessageSessionExpired)
+	} else if err != nil {
+		return repository.UserIdentity{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	return repository.UserIdentity{ID: id, Phone: phone}, nil
+}
+
+// ValidateToken reports whether token authenticates a principal. An invalid
+// token is not an error, it yields Valid false.
+func (s Service) ValidateToken(req ValidateTokenRequest) (ValidateTokenResponse, error) {
+	token := req.Token
+	if !strings.HasPrefix(token, "Bearer ") && !strings.HasPrefix(token, "ApiKey ") {
+		token = "Bearer " + token
+	}
+
+	principal, err := s.Authenticate(token)
+	if err != nil {
+		return ValidateTokenResponse{Valid: false}, nil
+	}
+
+	var expiresAt int64
+	if principal.Claims != nil && principal.Claims.ExpiresAt != nil {
+		expiresAt = principal.Claims.ExpiresAt.Unix()
+	}
+
+	return ValidateTokenResponse{
+		Valid:     true,
+		Principal: principal,
+		ExpiresAt: expiresAt,
+	}, nil
+}
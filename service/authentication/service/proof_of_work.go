@@ -0,0 +1,138 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hosseinasadian/chat-application/pkg/pow"
+	"github.com/hosseinasadian/chat-application/pkg/richerror"
+)
+
+// ProofOfWorkConfig makes callers of the listed routes solve a hashcash
+// puzzle first. Puzzles are signed instead of stored, any instance sharing
+// the secret accepts them.
+type ProofOfWorkConfig struct {
+	// Routes are paths such as "/send-otp" as they appear in the router,
+	// the gRPC calls of the same routes follow them. Empty turns the proof of
+	// work off.
+	Routes []string      `koanf:"routes"`
+	Secret string        `koanf:"secret"`
+	TTL    time.Duration `koanf:"ttl"`
+	// Difficulty is the number of leading zero bits asked for under normal
+	// load. Every doubling of the requests over LoadThreshold within
+	// LoadWindow adds a bit, up to MaxDifficulty.
+	Difficulty    int           `koanf:"difficulty"`
+	MaxDifficulty int           `koanf:"max_difficulty"`
+	LoadThreshold int           `koanf:"load_threshold"`
+	LoadWindow    time.Duration `koanf:"load_window"`
+}
+
+type proofOfWork struct {
+	routes map[string]bool
+	issuer pow.Issuer
+	meter  *pow.Meter
+}
+
+func newProofOfWork(config *ProofOfWorkConfig) proofOfWork {
+	if config.TTL <= 0 {
+		config.TTL = 2 * time.Minute
+	}
+	if config.Difficulty <= 0 {
+		config.Difficulty = 18
+	}
+	if config.MaxDifficulty < config.Difficulty {
+		config.MaxDifficulty = min(config.Difficulty+6, pow.MaxDifficulty)
+	}
+	if config.LoadThreshold <= 0 {
+		config.LoadThreshold = 600
+	}
+	if config.LoadWindow <= 0 {
+		config.LoadWindow = time.Minute
+	}
+
+	if len(config.Routes) == 0 {
+		return proofOfWork{}
+	}
+	if config.Secret == "" {
+		log.Println("proof of work disabled: no secret configured")
+		return proofOfWork{}
+	}
+
+	routes := make(map[string]bool, len(config.Routes))
+	for _, route := range config.Routes {
+		routes[strings.TrimSpace(route)] = true
+	}
+
+	return proofOfWork{
+		routes: routes,
+		issuer: pow.NewIssuer(config.Secret),
+		meter:  pow.NewMeter(config.LoadWindow),
+	}
+}
+
+// ProofOfWorkRequired reports whether requests to route must carry a solved
+// puzzle.
+func (s Service) ProofOfWorkRequired(route string) bool {
+	return s.proofOfWork.routes[route]
+}
+
+// ProofOfWorkChallenge issues a puzzle for route at the difficulty the
+// current load calls for. It is only good for route and the caller's IP.
+func (s Service) ProofOfWorkChallenge(route string, client ClientInfo) (StepUpChallenge, error) {
+	const op = "authentication.service.ProofOfWorkChallenge"
+
+	config := s.config.ProofOfWork
+	difficulty := pow.ScaleDifficulty(config.Difficulty, config.MaxDifficulty, s.proofOfWork.meter.Rate(), config.LoadThreshold)
+
+	puzzle, err := s.proofOfWork.issuer.Issue(difficulty, config.TTL, proofOfWorkBinding(route, client))
+	if err != nil {
+		return StepUpChallenge{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	return StepUpChallenge{
+		Type: StepUpProofOfWork,
+		Data: map[string]string{
+			"puzzle":     puzzle,
+			"difficulty": strconv.Itoa(difficulty),
+		},
+	}, nil
+}
+
+// VerifyProofOfWork checks solution, the puzzle and the nonce found for it
+// joined by a colon. Every call counts towards the load, solved or not. A
+// solution is accepted once, puzzles are remembered until they expire.
+func (s Service) VerifyProofOfWork(route string, client ClientInfo, solution string) error {
+	const op = "authentication.service.VerifyProofOfWork"
+
+	s.proofOfWork.meter.Observe()
+
+	if solution == "" {
+		return richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Proof of work is required")
+	}
+
+	puzzle, nonce, _ := strings.Cut(solution, ":")
+	if err := s.proofOfWork.issuer.Verify(puzzle, nonce, proofOfWorkBinding(route, client)); err != nil {
+		if errors.Is(err, pow.ErrExpired) {
+			return richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Proof of work puzzle has expired")
+		}
+		return richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Proof of work is invalid")
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	fresh, err := redisAdapter.Client().SetNX(redisAdapter.Context(), "pow-used:"+hashToken(puzzle), 1, s.config.ProofOfWork.TTL).Result()
+	if err != nil {
+		return richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+	if !fresh {
+		return richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Proof of work was already used")
+	}
+
+	return nil
+}
+
+func proofOfWorkBinding(route string, client ClientInfo) string {
+	return route + " " + client.IP
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/hosseinasadian/chat-application/pkg/richerror"
+	"github.com/hosseinasadian/chat-application/service/authentication/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	ReportReasonSpam          = "spam"
+	ReportReasonHarassment    = "harassment"
+	ReportReasonImpersonation = "impersonation"
+	ReportReasonOther         = "other"
+)
+
+var ReportReasons = []string{ReportReasonSpam, ReportReasonHarassment, ReportReasonImpersonation, ReportReasonOther}
+
+// ModerationReport is a user reporting another account. Reports are appended
+// to the "moderation-reports" stream, other services report content there
+// too, and are read by operators.
+type ModerationReport struct {
+	ID         string    `json:"id,omitempty"`
+	ReporterID string    `json:"reporter_id"`
+	UserID     string    `json:"user_id"`
+	Reason     string    `json:"reason"`
+	Details    string    `json:"details,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (s Service) ReportUser(req ReportUserRequest) (ReportUserResponse, error) {
+	const op = "authentication.service.ReportUser"
+
+	if !req.Principal.IsUser() {
+		return ReportUserResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Only users can report accounts")
+	}
+
+	if vErr := s.validator.validateReportUser(req); vErr != nil {
+		return ReportUserResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage(vErr.Error())
+	}
+
+	if req.UserID == req.Principal.Subject {
+		return ReportUserResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage("You cannot report yourself")
+	}
+	if _, err := s.userRepo.ByID(req.UserID); errors.Is(err, repository.ErrNotFound) {
+		return ReportUserResponse{}, richerror.New(op).WithKind(richerror.KindNotFound).WithMessage("User not found")
+	} else if err != nil {
+		return ReportUserResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	report := ModerationReport{
+		ReporterID: req.Principal.Subject,
+		UserID:     req.UserID,
+		Reason:     req.Reason,
+		Details:    req.Details,
+		CreatedAt:  time.Now().UTC(),
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return ReportUserResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	if xErr := redisAdapter.Client().XAdd(redisAdapter.Context(), &redis.XAddArgs{
+		Stream: "moderation-reports",
+		Values: map[string]any{"report": data},
+	}).Err(); xErr != nil {
+		return ReportUserResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(xErr)
+	}
+
+	return ReportUserResponse{Message: "Report received"}, nil
+}
+
+// ListReports returns moderation reports newest first. Next is set when
+// older reports exist and is passed back as Before.
+func (s Service) ListReports(req AdminListReportsRequest) (AdminListReportsResponse, error) {
+	const op = "authentication.service.ListReports"
+
+	actor, err := s.authorizeAdmin(op, req.Principal, PermissionReportsRead)
+	if err != nil {
+		return AdminListReportsResponse{}, err
+	}
+
+	if vErr := s.validator.validateAdminListReports(req); vErr != nil {
+		return AdminListReportsResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage(vErr.Error())
+	}
+	limit := req.Limit
+	if limit == 0 {
+		limit = 50
+	}
+
+	start := "+"
+	if req.Before != "" {
+		start = "(" + req.Before
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	entries, err := redisAdapter.Client().XRevRangeN(redisAdapter.Context(), "moderation-reports", start, "-", int64(limit)).Result()
+	if err != nil {
+		return AdminListReportsResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	reports := make([]ModerationReport, 0, len(entries))
+	for _, entry := range entries {
+		data, _ := entry.Values["report"].(string)
+		var report ModerationReport
+		if json.Unmarshal([]byte(data), &report) != nil {
+			continue
+		}
+		report.ID = entry.ID
+		reports = append(reports, report)
+	}
+
+	if aErr := s.auditAdmin(op, SecurityEvent{Type: SecurityEventAdminReportsViewed, ActorID: actor}); aErr != nil {
+		return AdminListReportsResponse{}, aErr
+	}
+
+	res := AdminListReportsResponse{Reports: reports}
+	if len(entries) == limit {
+		res.Next = entries[len(entries)-1].ID
+	}
+
+	return res, nil
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/hosseinasadian/chat-application/pkg/richerror"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	SecurityEventOtpRequested   = "otp_requested"
+	SecurityEventOtpVerified    = "otp_verified"
+	SecurityEventOtpFailed      = "otp_failed"
+	SecurityEventOtpBlocked     = "otp_blocked"
+	SecurityEventOtpChallenged  = "otp_challenged"
+	SecurityEventTokenRefreshed = "token_refreshed"
+	SecurityEventRefreshReuse   = "refresh_token_reuse"
+	SecurityEventLogout         = "logout"
+	SecurityEventSessionRevoked = "session_revoked"
+	SecurityEventPhoneChanged   = "phone_changed"
+	// SecurityEventAccountDeleted is only used as the reason sessions end
+	SecurityEventAccountDeleted = "account_deleted"
+
+	SecurityEventNewDevice               = "new_device"
+	SecurityEventDeviceApprovalRequested = "device_approval_requested"
+	SecurityEventDeviceApproved          = "device_approved"
+	SecurityEventDeviceDenied            = "device_denied"
+	SecurityEventDeviceRemoved           = "device_removed"
+	SecurityEventDeviceMismatch          = "device_secret_mismatch"
+
+	// actions of operators, ActorID tells who
+	SecurityEventAdminUserViewed      = "admin_user_viewed"
+	SecurityEventAdminSessionsRevoked = "admin_sessions_revoked"
+	SecurityEventAdminLoginUnlocked   = "admin_login_unlocked"
+	SecurityEventAdminPhoneBanned     = "admin_phone_banned"
+	SecurityEventAdminPhoneUnbanned   = "admin_phone_unbanned"
+	SecurityEventAdminBansViewed      = "admin_bans_viewed"
+	SecurityEventAdminReportsViewed   = "admin_reports_viewed"
+	SecurityEventAdminAuditQueried    = "admin_audit_queried"
+	SecurityEventAdminRoleChanged     = "admin_role_changed"
+	SecurityEventAdminRolesViewed     = "admin_roles_viewed"
+)
+
+type AuditConfig struct {
+	// LogMaxLen caps the shared audit log, the oldest entries are trimmed
+	// once it is reached. Zero keeps every entry, archive the stream before
+	// turning trimming on.
+	LogMaxLen int64 `koanf:"log_max_len"`
+	// EventsPerUser is how many events are kept for GET /me/security-events.
+	EventsPerUser int64 `koanf:"events_per_user"`
+}
+
+// ClientInfo is where a request came from.
+type ClientInfo struct {
+	IP        string `json:"ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	Location  string `json:"location,omitempty"`
+}
+
+// SecurityEvent is an entry of the audit log. ID is the position in the log
+// and doubles as the cursor of QueryAuditLog.
+type SecurityEvent struct {
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type"`
+	ActorID  string `json:"actor_id,omitempty"`
+	UserID   string `json:"user_id,omitempty"`
+	Phone    string `json:"phone,omitempty"`
+	DeviceID string `json:"device_id,omitempty"`
+	FamilyID string `json:"family_id,omitempty"`
+	JTI      string `json:"jti,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	ClientInfo
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// emitSecurityEvent appends the event to the audit log, keeps the latest
+// events of the user and publishes it on the "security-events" channel for
+// anything listening live. Events of phone numbers without an account only
+// go to the audit log. The error tells that the event is missing from the
+// audit log, the list of the user and the channel are best effort.
+func (s Service) emitSecurityEvent(event SecurityEvent) error {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now().UTC()
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("security event %s not audited: %v\n", event.Type, err)
+		return err
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	client, ctx := redisAdapter.Client(), redisAdapter.Context()
+
+	// trimming drops the oldest entries for good, it is only done when a
+	// maximum length is configured
+	args := &redis.XAddArgs{
+		Stream: "audit-log",
+		Values: map[string]any{"event": data},
+	}
+	if s.config.Audit.LogMaxLen > 0 {
+		args.MaxLen = s.config.Audit.LogMaxLen
+		args.Approx = true
+	}
+	id, err := client.XAdd(ctx, args).Result()
+	if err != nil {
+		log.Printf("security event %s not audited: %v\n", event.Type, err)
+		return err
+	}
+
+	event.ID = id
+	if data, err = json.Marshal(event); err != nil {
+		return nil
+	}
+
+	pipe := client.TxPipeline()
+	if event.UserID != "" {
+		key := "security-events:" + event.UserID
+		pipe.LPush(ctx, key, data)
+		pipe.LTrim(ctx, key, 0, s.config.Audit.EventsPerUser-1)
+	}
+	pipe.Publish(ctx, "security-events", data)
+	if _, err = pipe.Exec(ctx); err != nil {
+		log.Printf("security event %s audited as %s but not published: %v\n", event.Type, id, err)
+	}
+
+	return nil
+}
+
+// ListSecurityEvents returns the latest events of the user, newest first.
+func (s Service) ListSecurityEvents(req ListSecurityEventsRequest) (ListSecurityEventsResponse, error) {
+	const op = "authentication.service.ListSecurityEvents"
+
+	if !req.Principal.IsUser() {
+		return ListSecurityEventsResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Only users have security events")
+	}
+
+	if vErr := s.validator.validateListSecurityEvents(req, s.config.Audit.EventsPerUser); vErr != nil {
+		return ListSecurityEventsResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage(vErr.Error())
+	}
+	limit := req.Limit
+	if limit == 0 {
+		limit = 20
+	}
+
+	redisAdapter := s.otpRepo.Adapter()
+	stored, err := redisAdapter.Client().LRange(redisAdapter.Context(), "security-events:"+req.Principal.Subject, 0, int64(limit)-1).Result()
+	if err != nil {
+		return ListSecurityEventsResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	events := make([]SecurityEvent, 0, len(stored))
+	for _, data := range stored {
+		var event SecurityEvent
+		if json.Unmarshal([]byte(data), &event) == nil {
+			events = append(events, event)
+		}
+	}
+
+	return ListSecurityEventsResponse{Events: events}, nil
+}
+
+// QueryAuditLog searches the audit log of every user, newest first. Next is
+// set when older entries may match and is passed back as Before, a page may
+// hold fewer than Limit events when the filter matches rarely.
+func (s Service) QueryAuditLog(req QueryAuditLogRequest) (QueryAuditLogResponse, error) {
+	const op = "authentication.service.QueryAuditLog"
+
+	actor, err := s.authorizeAdmin(op, req.Principal, PermissionAuditRead)
+	if err != nil {
+		return QueryAuditLogResponse{}, err
+	}
+
+	if vErr := s.validator.validateQueryAuditLog(req); vErr != nil {
+		return QueryAuditLogResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage(vErr.Error())
+	}
+	limit := req.Limit
+	if limit == 0 {
+		limit = 50
+	}
+	if req.Phone != "" {
+		req.Phone = s.normalizePhone(req.Phone)
+	}
+
+	start, stop := "+", "-"
+	if req.Before != "" {
+		start = "(" + req.Before
+	} else if !req.Until.IsZero() {
+		start = strconv.FormatInt(req.Until.UnixMilli(), 10)
+	}
+	if !req.Since.IsZero() {
+		stop = strconv.FormatInt(req.Since.UnixMilli(), 10)
+	}
+
+	res, err := s.searchAuditLog(req, start, stop, limit)
+	if err != nil {
+		return QueryAuditLogResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	// logged once it ran so the query does not find itself
+	if aErr := s.auditAdmin(op, SecurityEvent{
+		Type:    SecurityEventAdminAuditQueried,
+		ActorID: actor,
+		UserID:  req.UserID,
+		Phone:   req.Phone,
+	}); aErr != nil {
+		return QueryAuditLogResponse{}, aErr
+	}
+
+	return res, nil
+}
+
+// auditScanLimit bounds the entries one audit log query reads. A filter that
+// matches rarely returns what it found so far with a cursor instead of
+// walking the whole log.
+const auditScanLimit = 10000
+
+// searchAuditLog walks the log from start down to stop in batches, reading
+// at most auditScanLimit entries.
+func (s Service) searchAuditLog(req QueryAuditLogRequest, start, stop string, limit int) (QueryAuditLogResponse, error) {
+	redisAdapter := s.otpRepo.Adapter()
+	client, ctx := redisAdapter.Client(), redisAdapter.Context()
+
+	const batch = 500
+	events := []SecurityEvent{}
+	for scanned := 0; ; {
+		entries, err := client.XRevRangeN(ctx, "audit-log", start, stop, batch).Result()
+		if err != nil {
+			return QueryAuditLogResponse{}, err
+		}
+
+		for _, entry := range entries {
+			data, _ := entry.Values["event"].(string)
+			var event SecurityEvent
+			if json.Unmarshal([]byte(data), &event) != nil {
+				continue
+			}
+			event.ID = entry.ID
+
+			if !auditMatches(req, event) {
+				continue
+			}
+			events = append(events, event)
+			if len(events) == limit {
+				return QueryAuditLogResponse{Events: events, Next: event.ID}, nil
+			}
+		}
+
+		if len(entries) < batch {
+			return QueryAuditLogResponse{Events: events}, nil
+		}
+
+		last := entries[len(entries)-1].ID
+		scanned += len(entries)
+		if scanned >= auditScanLimit {
+			return QueryAuditLogResponse{Events: events, Next: last}, nil
+		}
+		start = "(" + last
+	}
+}
+
+func auditMatches(req QueryAuditLogRequest, event SecurityEvent) bool {
+	return (req.UserID == "" || event.UserID == req.UserID) &&
+		(req.Phone == "" || event.Phone == req.Phone) &&
+		(req.Type == "" || event.Type == req.Type) &&
+		(req.ActorID == "" || event.ActorID == req.ActorID) &&
+		(req.IP == "" || event.IP == req.IP) &&
+		(req.DeviceID == "" || event.DeviceID == req.DeviceID)
+}
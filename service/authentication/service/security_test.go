@@ -0,0 +1,49 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestAuditLogSearchIsBounded(t *testing.T) {
+	s, _ := newTestService(t)
+	redisAdapter := s.otpRepo.Adapter()
+	client, ctx := redisAdapter.Client(), redisAdapter.Context()
+
+	addEvent := func(pipe redis.Pipeliner, event SecurityEvent) {
+		data, err := json.Marshal(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pipe.XAdd(ctx, &redis.XAddArgs{Stream: "audit-log", Values: map[string]any{"event": data}})
+	}
+
+	// the match is older than everything one query reads
+	pipe := client.Pipeline()
+	addEvent(pipe, SecurityEvent{Type: SecurityEventOtpFailed, UserID: "rare"})
+	for i := 0; i < auditScanLimit+100; i++ {
+		addEvent(pipe, SecurityEvent{Type: SecurityEventOtpFailed, UserID: "common"})
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	req := QueryAuditLogRequest{UserID: "rare"}
+	first, err := s.searchAuditLog(req, "+", "-", 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Events) != 0 || first.Next == "" {
+		t.Fatalf("first page = %d events, next %q, want none and a cursor", len(first.Events), first.Next)
+	}
+
+	second, err := s.searchAuditLog(req, "("+first.Next, "-", 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(second.Events) != 1 || second.Events[0].UserID != "rare" || second.Next != "" {
+		t.Fatalf("second page = %+v, want the match and no cursor", second)
+	}
+}
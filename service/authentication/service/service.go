@@ -3,9 +3,10 @@ package service
 import (
 	"errors"
 	"fmt"
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/google/uuid"
-	"github.com/hosseinasadian/chat-application/pkg/constant"
+	"github.com/hosseinasadian/chat-application/adapter/sms"
+	"github.com/hosseinasadian/chat-application/pkg/phone"
 	"github.com/redis/go-redis/v9"
 	"log"
 	"math/rand/v2"
@@ -17,23 +18,132 @@ import (
 )
 
 type Service struct {
-	config    Config
-	otpRepo   repository.OTP
-	validator Validator
+	config      Config
+	otpRepo     repository.OTP
+	passkeyRepo PasskeyRepository
+	userRepo    repository.User
+	webAuthn    *webauthn.WebAuthn
+	oidcSigner  *oidcSigner
+	emailSender EmailSender
+	smsSender   SMSSender
+	phones      phone.Parser
+	otpAbuse    otpAbuse
+	proofOfWork proofOfWork
+	validator   Validator
 }
 
-func New(config Config, otpRepo repository.OTP) Service {
-	validator := newValidator(config.OTPLength, constant.PhoneRegex)
-	return Service{otpRepo: otpRepo, config: config, validator: validator}
+func New(config Config, otpRepo repository.OTP, passkeyRepo PasskeyRepository, userRepo repository.User, emailSender EmailSender, smsSender SMSSender) Service {
+	phones := phone.New(config.Phone)
+	validator := newValidator(config.OTPLength, phones)
+
+	// passkeys stay disabled until a relying party is configured
+	var wa *webauthn.WebAuthn
+	if config.WebAuthn.RPID != "" {
+		if config.WebAuthn.CeremonyTimeout <= 0 {
+			config.WebAuthn.CeremonyTimeout = 5 * time.Minute
+		}
+
+		w, err := newWebAuthn(config.WebAuthn)
+		if err != nil {
+			log.Printf("passkeys disabled: %v\n", err)
+		} else {
+			wa = w
+		}
+	}
+
+	if config.Email.LoginTTL <= 0 {
+		config.Email.LoginTTL = 15 * time.Minute
+	}
+	if config.Email.VerificationTTL <= 0 {
+		config.Email.VerificationTTL = 15 * time.Minute
+	}
+
+	if config.PhoneChange.CodeTTL <= 0 {
+		config.PhoneChange.CodeTTL = 10 * time.Minute
+	}
+	if config.PhoneChange.Cooldown <= 0 {
+		config.PhoneChange.Cooldown = 30 * 24 * time.Hour
+	}
+	if config.PhoneChange.UserLimit <= 0 {
+		config.PhoneChange.UserLimit = 5
+	}
+	if config.PhoneChange.NumberLimit <= 0 {
+		config.PhoneChange.NumberLimit = 3
+	}
+	if config.PhoneChange.LimitWindow <= 0 {
+		config.PhoneChange.LimitWindow = 24 * time.Hour
+	}
+
+	if config.Account.DeletionGracePeriod <= 0 {
+		config.Account.DeletionGracePeriod = 30 * 24 * time.Hour
+	}
+	if config.Account.ExportDir == "" {
+		config.Account.ExportDir = "./tmp/exports"
+	}
+	if config.Account.ExportTTL <= 0 {
+		config.Account.ExportTTL = 7 * 24 * time.Hour
+	}
+	if config.Account.JobInterval <= 0 {
+		config.Account.JobInterval = time.Minute
+	}
+
+	if config.Audit.EventsPerUser <= 0 {
+		config.Audit.EventsPerUser = 100
+	}
+
+	if config.Devices.ApprovalTTL <= 0 {
+		config.Devices.ApprovalTTL = 10 * time.Minute
+	}
+
+	abuse := newOtpAbuse(&config.OtpAbuse, func(raw string) string {
+		if normalized, err := phones.Normalize(raw); err == nil {
+			return normalized
+		}
+		return raw
+	})
+
+	work := newProofOfWork(&config.ProofOfWork)
+
+	// the OpenID Connect provider is off until an issuer is configured
+	var signer *oidcSigner
+	if config.OIDC.Issuer != "" {
+		if config.OIDC.RequestTTL <= 0 {
+			config.OIDC.RequestTTL = 10 * time.Minute
+		}
+		if config.OIDC.CodeTTL <= 0 {
+			config.OIDC.CodeTTL = time.Minute
+		}
+		if config.OIDC.IDTokenTTL <= 0 {
+			config.OIDC.IDTokenTTL = time.Hour
+		}
+
+		o, err := newOIDCSigner(config.OIDC.SigningKeyFile)
+		if err != nil {
+			log.Printf("openid connect disabled: %v\n", err)
+		} else {
+			signer = o
+		}
+	}
+
+	return Service{otpRepo: otpRepo, passkeyRepo: passkeyRepo, userRepo: userRepo, webAuthn: wa, oidcSigner: signer, emailSender: emailSender, smsSender: smsSender, phones: phones, otpAbuse: abuse, proofOfWork: work, config: config, validator: validator}
 }
 
 func (s Service) SendOtp(req SendOtpRequest) (SendOtpResponse, error) {
 	const op = "authentication.service.SendOtp"
 
+	req.Phone = s.normalizePhone(req.Phone)
 	if vErr := s.validator.validateSendOtp(req); vErr != nil {
 		return SendOtpResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage(vErr.Error())
 	}
 
+	if bErr := s.checkPhoneBan(op, req.Phone); bErr != nil {
+		return SendOtpResponse{}, bErr
+	}
+
+	if res, aErr := s.screenOtpRequest(op, req); aErr != nil || res.ChallengeRequired {
+		return res, aErr
+	}
+
 	otp := fmt.Sprintf("%06d", rand.IntN(1000000))
 
 	redisAdapter := s.otpRepo.Adapter()
@@ -41,61 +151,223 @@ func (s Service) SendOtp(req SendOtpRequest) (SendOtpResponse, error) {
 		return SendOtpResponse{}, richerror.New(op).WithWrapper(rsErr)
 	}
 
-	// TODO: send SMS using gateway
-	log.Printf("Phone: %s, OTP: %s\n", req.Phone, otp)
+	if dErr := s.deliverOtp(req.Phone, otp); dErr != nil {
+		return SendOtpResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithMessage("Failed to send OTP").WithWrapper(dErr)
+	}
+
+	s.emitSecurityEvent(SecurityEvent{
+		Type:       SecurityEventOtpRequested,
+		UserID:     s.existingUserID(req.Phone),
+		Phone:      req.Phone,
+		ClientInfo: req.Client,
+	})
 
 	return SendOtpResponse{Message: "OTP sent"}, nil
 }
 
+// SMSSender delivers one time codes, see adapter/sms for the drivers.
+type SMSSender interface {
+	Send(message sms.Message) error
+}
+
+// deliverOtp sends code to phone by SMS.
+func (s Service) deliverOtp(phone, code string) error {
+	return s.smsSender.Send(sms.Message{
+		To:   phone,
+		Body: fmt.Sprintf("Your Chat Room code is %s. Do not share it with anyone.", code),
+	})
+}
+
+// existingUserID returns the user id of phone, empty when the number has no
+// account yet.
+func (s Service) existingUserID(phone string) string {
+	id, _ := s.userRepo.IDByPhone(phone)
+	return id
+}
+
 func (s Service) VerifyOtp(req VerifyOtpRequest) (VerifyOtpResponse, error) {
 	const op = "authentication.service.VerifyOtp"
 
+	req.Phone = s.normalizePhone(req.Phone)
 	if vErr := s.validator.validateVerifyOtp(req); vErr != nil {
 		return VerifyOtpResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage(vErr.Error())
 	}
 
+	if bErr := s.checkPhoneBan(op, req.Phone); bErr != nil {
+		return VerifyOtpResponse{}, bErr
+	}
+
 	redisAdapter := s.otpRepo.Adapter()
 
+	failed := SecurityEvent{
+		Type:       SecurityEventOtpFailed,
+		Phone:      req.Phone,
+		DeviceID:   req.DeviceID,
+		ClientInfo: req.Client,
+	}
+
 	stored, err := redisAdapter.Client().Get(redisAdapter.Context(), "otp:"+req.Phone).Result()
 	if errors.Is(err, redis.Nil) {
+		failed.UserID, failed.Reason = s.existingUserID(req.Phone), "expired"
+		s.emitSecurityEvent(failed)
 		return VerifyOtpResponse{}, richerror.New(op).WithKind(richerror.KindGone).WithMessage("OTP has expired")
 	} else if err != nil {
 		return VerifyOtpResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithMessage(http.StatusText(http.StatusInternalServerError))
 	}
 
 	if stored != req.Otp {
+		failed.UserID, failed.Reason = s.existingUserID(req.Phone), "invalid_code"
+		s.emitSecurityEvent(failed)
 		return VerifyOtpResponse{}, richerror.New(op).WithKind(richerror.KindInvalid).WithMessage("Invalid OTP code")
 	}
 
-	// assign or accept deviceId
-	deviceID := req.DeviceID
+	// Clean up OTP
+	_ = redisAdapter.Client().Del(redisAdapter.Context(), "otp:"+req.Phone).Err()
+
+	// the first successful login assigns the user id
+	identity, err := s.userRepo.FindOrCreate(req.Phone)
+	if err != nil {
+		return VerifyOtpResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	// a device id the caller cannot prove is ignored, the login counts as a
+	// new device
+	deviceID := s.provenDevice(identity.ID, req.DeviceID, req.DeviceSecret)
+
+	s.emitSecurityEvent(SecurityEvent{
+		Type:       SecurityEventOtpVerified,
+		UserID:     identity.ID,
+		Phone:      identity.Phone,
+		DeviceID:   deviceID,
+		ClientInfo: req.Client,
+	})
+
+	return s.completeLogin(identity, deviceID, req.Client)
+}
+
+// normalizePhone returns raw in E.164 form, or raw itself when it is not a
+// valid number so the validator can report why.
+func (s Service) normalizePhone(raw string) string {
+	normalized, err := s.phones.Normalize(raw)
+	if err != nil {
+		return raw
+	}
+
+	return normalized
+}
+
+// completeLogin runs once the first factor of the user is proven, deviceID
+// is either proven by its secret or empty for a new device. A device the user
+// has not logged in from waits for approval first when the user asked for it.
+func (s Service) completeLogin(identity repository.UserIdentity, deviceID string, client ClientInfo) (VerifyOtpResponse, error) {
+	const op = "authentication.service.completeLogin"
+
+	if deviceID == "" {
+		deviceID = uuid.NewString()
+	}
+
+	needsApproval, err := s.needsDeviceApproval(identity.ID, deviceID)
+	if err != nil {
+		return VerifyOtpResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+	if needsApproval {
+		token, aErr := s.requestDeviceApproval(identity.ID, deviceID, client)
+		if aErr != nil {
+			return VerifyOtpResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(aErr)
+		}
+
+		return VerifyOtpResponse{ApprovalRequired: true, ApprovalToken: token, DeviceID: deviceID}, nil
+	}
+
+	return s.completeApprovedLogin(identity, deviceID, client)
+}
+
+// completeApprovedLogin asks for the second factor when one is enabled,
+// otherwise it starts the session.
+func (s Service) completeApprovedLogin(identity repository.UserIdentity, deviceID string, client ClientInfo) (VerifyOtpResponse, error) {
+	const op = "authentication.service.completeApprovedLogin"
+
+	if s.totpEnabled(identity.ID) {
+		challenge, cErr := s.createMFAChallenge(identity.ID, deviceID)
+		if cErr != nil {
+			return VerifyOtpResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithMessage("Failed to start second factor")
+		}
+
+		return VerifyOtpResponse{MFARequired: true, MFAToken: challenge}, nil
+	}
+
+	pair, err := s.issueSession(identity, deviceID, client)
+	if err != nil {
+		return VerifyOtpResponse{}, richerror.New(op).WithWrapper(err)
+	}
+
+	return VerifyOtpResponse{AccessToken: pair.AccessToken, RefreshToken: pair.RefreshToken, DeviceID: pair.DeviceID, DeviceSecret: pair.DeviceSecret}, nil
+}
+
+// issueSession logs the user in on deviceID, assigning a device id when the
+// client has none, and returns the first token pair of a new family. Callers
+// only pass device ids the client has proven, a device without a secret is
+// issued one along with the tokens.
+func (s Service) issueSession(identity repository.UserIdentity, deviceID string, client ClientInfo) (TokenPair, error) {
+	const op = "authentication.service.issueSession"
+
+	// logins through email or a passkey are refused as well
+	if bErr := s.checkPhoneBan(op, identity.Phone); bErr != nil {
+		return TokenPair{}, bErr
+	}
+
 	if deviceID == "" {
 		deviceID = uuid.NewString()
 	}
 
-	access, iaErr := s.issueAccess(req.Phone, deviceID)
+	deviceSecret, err := s.bindDevice(identity.ID, deviceID)
+	if err != nil {
+		return TokenPair{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithMessage("Failed to persist session")
+	}
+
+	// every login starts a new refresh token family
+	pair, err := s.startFamily(newTokenFamily(identity, deviceID))
+	if err != nil {
+		return TokenPair{}, err
+	}
+	pair.DeviceSecret = deviceSecret
+
+	s.recordDevice(identity.ID, deviceID, client, true)
+
+	return pair, nil
+}
+
+// startFamily issues the first token pair of family and persists the session.
+func (s Service) startFamily(family TokenFamily) (TokenPair, error) {
+	const op = "authentication.service.startFamily"
+
+	userID, deviceID := family.UserID, family.DeviceID
+
+	access, iaErr := s.issueAccess(family)
 	if iaErr != nil {
-		return VerifyOtpResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithMessage("Failed to generate access token")
+		return TokenPair{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithMessage("Failed to generate access token")
 	}
 
-	refresh, jti, irErr := s.issueRefresh(req.Phone, deviceID)
+	refresh, jti, irErr := s.issueRefresh(family)
 	if irErr != nil {
-		return VerifyOtpResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithMessage("Failed to generate refresh token")
+		return TokenPair{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithMessage("Failed to generate refresh token")
 	}
 
-	// Save latest refresh for this device
-	if sErr := s.saveRefresh(req.Phone, deviceID, refresh); sErr != nil {
-		return VerifyOtpResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithMessage("Failed to persist session")
+	family.CurrentJTI = jti
+	if fErr := s.saveFamily(family); fErr != nil {
+		return TokenPair{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithMessage("Failed to persist session")
 	}
 
-	// Clean up OTP
-	_ = redisAdapter.Client().Del(redisAdapter.Context(), "otp:"+req.Phone).Err()
+	// Save latest refresh for this device
+	if sErr := s.saveRefresh(userID, deviceID, refresh); sErr != nil {
+		return TokenPair{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithMessage("Failed to persist session")
+	}
 
 	// Optional: store meta
-	_ = redisAdapter.Client().Set(redisAdapter.Context(), "refresh-meta:"+jti, fmt.Sprintf(`{"phone":"%s","deviceId":"%s"}`, req.Phone, deviceID), s.config.AccessTokenTTL).Err()
-
-	return VerifyOtpResponse{AccessToken: access, RefreshToken: refresh, DeviceID: deviceID}, nil
+	redisAdapter := s.otpRepo.Adapter()
+	_ = redisAdapter.Client().Set(redisAdapter.Context(), "refresh-meta:"+jti, fmt.Sprintf(`{"userId":"%s","deviceId":"%s","familyId":"%s"}`, userID, deviceID, family.ID), s.config.RefreshTokenTTL).Err()
 
+	return TokenPair{AccessToken: access, RefreshToken: refresh, DeviceID: deviceID}, nil
 }
 
 func (s Service) RefreshToken(req RefreshRequest) (RefreshResponse, error) {
@@ -107,31 +379,55 @@ func (s Service) RefreshToken(req RefreshRequest) (RefreshResponse, error) {
 
 	redisAdapter := s.otpRepo.Adapter()
 
-	token, err := jwt.Parse(req.RefreshToken, func(t *jwt.Token) (interface{}, error) {
-		return []byte(s.config.AccessTokenSecret), nil
-	})
-	if err != nil || !token.Valid {
+	claims, err := s.parseClaims(req.RefreshToken, TokenTypeRefresh)
+	if err != nil {
 		return RefreshResponse{}, richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage("Invalid refresh token")
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return RefreshResponse{}, richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage("Invalid refresh token")
+	subject := claims.Subject
+	deviceID := claims.DeviceID
+	jti := claims.ID
+
+	if s.isFamilyRevoked(claims.FamilyID) {
+		return RefreshResponse{}, richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage(s.revokedFamilyMessage(claims.FamilyID))
 	}
 
-	phone, _ := claims["sub"].(string)
-	deviceID, _ := claims["did"].(string)
-	jti, _ := claims["jti"].(string)
+	if s.isBlacklisted(jti) {
+		return RefreshResponse{}, richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage("Invalid refresh token")
+	}
 
-	if phone == "" || deviceID == "" || jti == "" {
+	family, err := s.getFamily(claims.FamilyID)
+	if errors.Is(err, redis.Nil) {
 		return RefreshResponse{}, richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage("Invalid refresh token")
+	} else if err != nil {
+		return RefreshResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
 	}
 
-	if s.isBlacklisted(jti) {
+	// tokens of an OAuth client may only be refreshed by that client
+	if family.ClientID != req.ClientID {
 		return RefreshResponse{}, richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage("Invalid refresh token")
 	}
 
-	storedToken, err := s.getRefresh(phone, deviceID)
+	// a rotated-out member of the family is being replayed
+	if family.CurrentJTI != jti {
+		s.revokeFamilyOnReuse(family, jti, req.Client)
+		return RefreshResponse{}, richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage(MessageSuspiciousActivity)
+	}
+
+	// sessions of a login are bound to the device, the token is only good
+	// together with the device secret. OAuth clients prove themselves instead.
+	bound := false
+	if family.ClientID == "" && family.UserID != "" {
+		if bound, err = s.deviceBound(family.UserID, deviceID); err != nil {
+			return RefreshResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+		}
+		if bound && !s.deviceSecretMatches(family.UserID, deviceID, req.DeviceSecret) {
+			s.revokeFamilyOnDeviceMismatch(family, jti, req.Client)
+			return RefreshResponse{}, richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage(MessageSuspiciousActivity)
+		}
+	}
+
+	storedToken, err := s.getRefresh(subject, deviceID)
 	if errors.Is(err, redis.Nil) {
 		return RefreshResponse{}, richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage("Invalid refresh token")
 	} else if err != nil {
@@ -142,52 +438,111 @@ func (s Service) RefreshToken(req RefreshRequest) (RefreshResponse, error) {
 		return RefreshResponse{}, richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage("Invalid refresh token")
 	}
 
+	// two concurrent exchanges of the current token, one of them is a replay
 	firstUse, err := s.markUsedOnce(jti)
 	if err != nil {
 		return RefreshResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
 	}
 	if !firstUse {
-		s.deleteRefresh(phone, deviceID)
-		s.blacklistJTI(jti)
-		return RefreshResponse{}, richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage("Invalid refresh token")
+		s.revokeFamilyOnReuse(family, jti, req.Client)
+		return RefreshResponse{}, richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage(MessageSuspiciousActivity)
+	}
+
+	// sessions started while tokens were issued to the phone number move
+	// to the user id on their next rotation
+	if family.UserID == "" {
+		identity, lErr := s.legacyIdentity(family.Phone)
+		if lErr != nil {
+			return RefreshResponse{}, richerror.New(op).WithWrapper(lErr)
+		}
+
+		s.deleteRefresh(subject, deviceID)
+		family.UserID, family.Phone = identity.ID, identity.Phone
+		subject = identity.ID
+	}
+
+	// sessions started before devices had secrets get one now
+	var deviceSecret string
+	if family.ClientID == "" && !bound {
+		if deviceSecret, err = s.bindDevice(family.UserID, deviceID); err != nil {
+			return RefreshResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+		}
 	}
 
-	access, err := s.issueAccess(phone, deviceID)
+	access, err := s.issueAccess(family)
 	if err != nil {
 		return RefreshResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
 	}
 
-	newRefresh, newJTI, err := s.issueRefresh(phone, deviceID)
+	newRefresh, newJTI, err := s.issueRefresh(family)
 	if err != nil {
 		return RefreshResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
 	}
 
-	if err := s.saveRefresh(phone, deviceID, newRefresh); err != nil {
+	if err := s.saveRefresh(subject, deviceID, newRefresh); err != nil {
+		return RefreshResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	family.CurrentJTI = newJTI
+	family.RotatedAt = time.Now().UTC()
+	if err := s.saveFamily(family); err != nil {
 		return RefreshResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
 	}
 
 	// Optional: store new meta
-	_ = redisAdapter.Client().Set(redisAdapter.Context(), "refresh-meta:"+newJTI, fmt.Sprintf(`{"phone":"%s","deviceId":"%s"}`, phone, deviceID), s.config.RefreshTokenTTL).Err()
+	_ = redisAdapter.Client().Set(redisAdapter.Context(), "refresh-meta:"+newJTI, fmt.Sprintf(`{"userId":"%s","deviceId":"%s","familyId":"%s"}`, subject, deviceID, family.ID), s.config.RefreshTokenTTL).Err()
+
+	s.recordDevice(family.UserID, deviceID, req.Client, false)
+	s.emitSecurityEvent(SecurityEvent{
+		Type:       SecurityEventTokenRefreshed,
+		UserID:     family.subject(),
+		DeviceID:   deviceID,
+		FamilyID:   family.ID,
+		JTI:        newJTI,
+		ClientInfo: req.Client,
+	})
 
-	return RefreshResponse{AccessToken: access, RefreshToken: newRefresh, DeviceID: deviceID}, nil
+	return RefreshResponse{AccessToken: access, RefreshToken: newRefresh, DeviceID: deviceID, DeviceSecret: deviceSecret}, nil
 }
 
 func (s Service) Me(req MeRequest) (MeResponse, error) {
 	const op = "authentication.service.Me"
 
-	claims, ok := req.Claims.(jwt.MapClaims)
-	if !ok {
+	if !req.Principal.HasScope(ScopeProfileRead) {
+		return MeResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("Missing scope " + ScopeProfileRead)
+	}
+
+	if req.Principal.Kind == PrincipalBot {
+		bot, err := s.getBot(req.Principal.Subject)
+		if err != nil {
+			return MeResponse{}, richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage(http.StatusText(http.StatusUnauthorized))
+		}
+
+		return MeResponse{UserName: bot.Name}, nil
+	}
+
+	userID := req.Principal.Subject
+	if userID == "" {
 		return MeResponse{}, richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage(http.StatusText(http.StatusUnauthorized))
 	}
 
-	phone := claims["sub"].(string) // or int if you use that type
+	// linked email is optional
+	address, _ := s.emailOf(userID)
 
-	// todo get user from db with phone and fill MeResponse with that user information
+	scheduled, err := s.pendingDeletion(userID)
+	if err != nil {
+		return MeResponse{}, richerror.New(op).WithKind(richerror.KindUnexpected).WithWrapper(err)
+	}
+
+	// todo get user from db with id and fill MeResponse with that user information
 	return MeResponse{
-		ID:       1,
+		ID:       userID,
 		UserName: "hossein",
 		Avatar:   "https://avatar.iran.liara.run/public/8",
-		Phone:    phone,
+		Phone:    req.Principal.Phone,
+		Email:    address,
+
+		DeletionScheduledFor: scheduled,
 	}, nil
 
 }
@@ -195,17 +550,31 @@ func (s Service) Me(req MeRequest) (MeResponse, error) {
 func (s Service) Logout(req LogoutRequest) (LogoutResponse, error) {
 	const op = "authentication.service.Logout"
 
-	claims, ok := req.Claims.(jwt.MapClaims)
-	if !ok {
-		return LogoutResponse{}, richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage("Invalid authentication context")
+	if !req.Principal.IsUser() {
+		return LogoutResponse{}, richerror.New(op).WithKind(richerror.KindForbidden).WithMessage("API keys cannot log out, revoke the key instead")
 	}
 
-	phone, _ := claims["sub"].(string)
-	deviceID, _ := claims["did"].(string)
+	// the session of a legacy token is still stored under its phone subject
+	subject := req.Principal.Subject
+	if req.Principal.Claims != nil {
+		subject = req.Principal.Claims.Subject
+	}
+	deviceID := req.Principal.DeviceID
+
+	if subject != "" && deviceID != "" {
+		s.deleteRefresh(subject, deviceID)
+	}
 
-	if phone != "" && deviceID != "" {
-		s.deleteRefresh(phone, deviceID)
+	event := SecurityEvent{
+		Type:       SecurityEventLogout,
+		UserID:     req.Principal.Subject,
+		DeviceID:   deviceID,
+		ClientInfo: req.Client,
+	}
+	if req.Principal.Claims != nil {
+		event.FamilyID = req.Principal.Claims.FamilyID
 	}
+	s.emitSecurityEvent(event)
 
 	return LogoutResponse{
 		Message: "Logged out successfully",
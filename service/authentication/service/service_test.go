@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	redisAdapter "github.com/hosseinasadian/chat-application/adapter/redis"
+	"github.com/hosseinasadian/chat-application/service/authentication/repository"
+)
+
+// newTestService runs the service against an in-memory Redis and creates
+// one user.
+func newTestService(t *testing.T) (Service, repository.UserIdentity) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	port, err := strconv.Atoi(mr.Port())
+	if err != nil {
+		t.Fatal(err)
+	}
+	adapter, err := redisAdapter.New(context.Background(), redisAdapter.Config{Host: mr.Host(), Port: port})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(Config{
+		AccessTokenSecret:  "access-secret",
+		AccessTokenTTL:     15 * time.Minute,
+		RefreshTokenSecret: "refresh-secret",
+		RefreshTokenTTL:    24 * time.Hour,
+		OTPLength:          6,
+		Issuer:             "chat-room-auth",
+		Audience:           "chat-room",
+		WebAuthn: WebAuthnConfig{
+			RPID:          testRPID,
+			RPDisplayName: "Chat Room",
+			RPOrigins:     []string{testOrigin},
+		},
+	}, repository.New(*adapter), repository.NewPasskey(*adapter), repository.NewUser(*adapter), nil, nil)
+
+	identity, err := s.userRepo.FindOrCreate("+989121234567")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s, identity
+}
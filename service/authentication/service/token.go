@@ -1,6 +1,10 @@
 package service
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
@@ -9,47 +13,127 @@ import (
 	"time"
 )
 
-func (s Service) issueAccess(phone, deviceID string) (string, error) {
-	claims := jwt.MapClaims{
-		"sub":   phone,
-		"did":   deviceID,
-		"scope": "access",
-		"exp":   time.Now().Add(s.config.AccessTokenTTL).Unix(),
-		"iat":   time.Now().Unix(),
+type TokenType string
+
+const (
+	TokenTypeAccess  TokenType = "access"
+	TokenTypeRefresh TokenType = "refresh"
+)
+
+// Claims is the payload of every token the service issues. Type tells access
+// and refresh tokens apart and is checked on every parse, so one can never be
+// used in place of the other even if both were signed with the same secret.
+//
+// Subject is the opaque user id and Phone the number the user logged in with.
+// Tokens issued before user ids were introduced have the phone number as
+// subject and no Phone claim.
+//
+// ClientID and Scope are set on tokens issued to an OAuth client, they limit
+// what the holder may do on behalf of the user.
+type Claims struct {
+	jwt.RegisteredClaims
+	DeviceID string    `json:"did"`
+	FamilyID string    `json:"fid"`
+	Type     TokenType `json:"typ"`
+	Phone    string    `json:"phone,omitempty"`
+	ClientID string    `json:"client_id,omitempty"`
+	Scope    string    `json:"scope,omitempty"`
+}
+
+func (s Service) newClaims(typ TokenType, family TokenFamily, ttl time.Duration) Claims {
+	now := time.Now()
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   family.subject(),
+			Issuer:    s.config.Issuer,
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        uuid.NewString(),
+		},
+		DeviceID: family.DeviceID,
+		FamilyID: family.ID,
+		Type:     typ,
+		Phone:    family.Phone,
+		ClientID: family.ClientID,
+		Scope:    family.Scope,
+	}
+	if s.config.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{s.config.Audience}
 	}
+
+	return claims
+}
+
+func (s Service) issueAccess(family TokenFamily) (string, error) {
+	claims := s.newClaims(TokenTypeAccess, family, s.config.AccessTokenTTL)
 	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return t.SignedString([]byte(s.config.AccessTokenSecret))
 }
 
-func (s Service) issueRefresh(phone, deviceID string) (tokenString string, jti string, err error) {
-	jti = uuid.NewString()
-	claims := jwt.MapClaims{
-		"sub": phone,
-		"did": deviceID,
-		"jti": jti,
-		"scp": "refresh",
-		"exp": time.Now().Add(s.config.RefreshTokenTTL).Unix(),
-		"iat": time.Now().Unix(),
-	}
+func (s Service) issueRefresh(family TokenFamily) (tokenString string, jti string, err error) {
+	claims := s.newClaims(TokenTypeRefresh, family, s.config.RefreshTokenTTL)
 	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err = t.SignedString([]byte(s.config.RefreshTokenSecret))
-	return
+	return tokenString, claims.ID, err
+}
+
+// parseClaims verifies tokenString as a token of type typ. The signing
+// algorithm is pinned to HS256 and the key is chosen by the expected type,
+// then issuer, audience, expiry and the typ claim are all enforced.
+func (s Service) parseClaims(tokenString string, typ TokenType) (*Claims, error) {
+	secret := s.config.AccessTokenSecret
+	if typ == TokenTypeRefresh {
+		secret = s.config.RefreshTokenSecret
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(s.config.TokenLeeway),
+	}
+	if s.config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(s.config.Issuer))
+	}
+	if s.config.Audience != "" {
+		options = append(options, jwt.WithAudience(s.config.Audience))
+	}
+
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	}, options...)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+
+	if claims.Type != typ {
+		return nil, fmt.Errorf("%w: expected %s token, got %q", jwt.ErrTokenInvalidClaims, typ, claims.Type)
+	}
+	if claims.Subject == "" || claims.DeviceID == "" || claims.FamilyID == "" || claims.ID == "" {
+		return nil, fmt.Errorf("%w: missing sub, did, fid or jti", jwt.ErrTokenInvalidClaims)
+	}
+
+	return claims, nil
 }
 
-func (s Service) saveRefresh(phone, deviceID, refresh string) error {
-	key := fmt.Sprintf("refresh:%s:%s", phone, deviceID)
+func (s Service) saveRefresh(subject, deviceID, refresh string) error {
+	key := fmt.Sprintf("refresh:%s:%s", subject, deviceID)
 	redisAdapter := s.otpRepo.Adapter()
 	return redisAdapter.Client().Set(redisAdapter.Context(), key, refresh, s.config.RefreshTokenTTL).Err()
 }
 
-func (s Service) getRefresh(phone, deviceID string) (string, error) {
-	key := fmt.Sprintf("refresh:%s:%s", phone, deviceID)
+func (s Service) getRefresh(subject, deviceID string) (string, error) {
+	key := fmt.Sprintf("refresh:%s:%s", subject, deviceID)
 	redisAdapter := s.otpRepo.Adapter()
 	return redisAdapter.Client().Get(redisAdapter.Context(), key).Result()
 }
 
-func (s Service) deleteRefresh(phone, deviceID string) {
-	key := fmt.Sprintf("refresh:%s:%s", phone, deviceID)
+func (s Service) deleteRefresh(subject, deviceID string) {
+	key := fmt.Sprintf("refresh:%s:%s", subject, deviceID)
 	redisAdapter := s.otpRepo.Adapter()
 	_ = redisAdapter.Client().Del(redisAdapter.Context(), key).Err()
 }
@@ -72,7 +156,7 @@ func (s Service) isBlacklisted(jti string) bool {
 	return err == nil
 }
 
-func (s Service) ParseToken(bearerToken string) (jwt.MapClaims, error) {
+func (s Service) ParseToken(bearerToken string) (*Claims, error) {
 	const op = "authentication/service.ParseToken"
 
 	if bearerToken == "" {
@@ -84,16 +168,24 @@ func (s Service) ParseToken(bearerToken string) (jwt.MapClaims, error) {
 		return nil, richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage("Invalid token format")
 	}
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte(s.config.AccessTokenSecret), nil
-	})
-	if err != nil || !token.Valid {
-		return nil, richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage("Invalid token")
+	claims, err := s.parseClaims(tokenString, TokenTypeAccess)
+	if err != nil {
+		return nil, richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage("Invalid token").WithWrapper(err)
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok {
-		return claims, nil
+	return claims, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
 	}
 
-	return nil, richerror.New(op).WithKind(richerror.KindUnauthorized).WithMessage("Invalid claims")
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(raw), nil
 }
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testLeeway = 30 * time.Second
+
+func newTokenTestService(accessSecret, refreshSecret string) Service {
+	return Service{config: Config{
+		AccessTokenSecret:  accessSecret,
+		AccessTokenTTL:     15 * time.Minute,
+		RefreshTokenSecret: refreshSecret,
+		RefreshTokenTTL:    24 * time.Hour,
+		Issuer:             "chat-room-auth",
+		Audience:           "chat-room",
+		TokenLeeway:        testLeeway,
+	}}
+}
+
+var testFamily = TokenFamily{ID: "family-1", UserID: "user-1", Phone: "+989121234567", DeviceID: "device-1"}
+
+func sign(t *testing.T, method jwt.SigningMethod, claims jwt.Claims, key any) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+
+	return token
+}
+
+func TestParseClaimsAcceptsIssuedTokens(t *testing.T) {
+	s := newTokenTestService("access-secret", "refresh-secret")
+
+	access, err := s.issueAccess(testFamily)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.parseClaims(access, TokenTypeAccess); err != nil {
+		t.Errorf("access token rejected: %v", err)
+	}
+
+	refresh, _, err := s.issueRefresh(testFamily)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.parseClaims(refresh, TokenTypeRefresh); err != nil {
+		t.Errorf("refresh token rejected: %v", err)
+	}
+}
+
+func TestParseClaimsRejectsTokenOfOtherType(t *testing.T) {
+	// with equal secrets only the typ claim tells the tokens apart
+	s := newTokenTestService("shared-secret", "shared-secret")
+
+	refresh, _, err := s.issueRefresh(testFamily)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.parseClaims(refresh, TokenTypeAccess); err == nil {
+		t.Error("refresh token accepted as access token")
+	}
+	if _, err := s.ParseToken("Bearer " + refresh); err == nil {
+		t.Error("refresh token accepted as bearer token")
+	}
+
+	access, err := s.issueAccess(testFamily)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.parseClaims(access, TokenTypeRefresh); err == nil {
+		t.Error("access token accepted as refresh token")
+	}
+}
+
+func TestParseClaimsRejectsOtherAlgorithms(t *testing.T) {
+	s := newTokenTestService("access-secret", "refresh-secret")
+	claims := s.newClaims(TokenTypeAccess, testFamily, time.Minute)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"none", sign(t, jwt.SigningMethodNone, claims, jwt.UnsafeAllowNoneSignatureType)},
+		{"RS256", sign(t, jwt.SigningMethodRS256, claims, rsaKey)},
+		// the public key is published, a verifier mixing up algorithms would
+		// take it as the HMAC secret
+		{"HS256 keyed with public key", sign(t, jwt.SigningMethodHS256, claims, publicPEM)},
+		{"HS512 with right secret", sign(t, jwt.SigningMethodHS512, claims, []byte("access-secret"))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.parseClaims(tt.token, TokenTypeAccess); err == nil {
+				t.Error("token accepted")
+			}
+		})
+	}
+}
+
+func TestParseClaimsEnforcesIssuerAndAudience(t *testing.T) {
+	s := newTokenTestService("access-secret", "refresh-secret")
+
+	tests := []struct {
+		name   string
+		modify func(*Claims)
+	}{
+		{"wrong issuer", func(c *Claims) { c.Issuer = "someone-else" }},
+		{"missing issuer", func(c *Claims) { c.Issuer = "" }},
+		{"wrong audience", func(c *Claims) { c.Audience = jwt.ClaimStrings{"other-service"} }},
+		{"missing audience", func(c *Claims) { c.Audience = nil }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := s.newClaims(TokenTypeAccess, testFamily, time.Minute)
+			tt.modify(&claims)
+
+			token := sign(t, jwt.SigningMethodHS256, claims, []byte("access-secret"))
+			if _, err := s.parseClaims(token, TokenTypeAccess); err == nil {
+				t.Error("token accepted")
+			}
+		})
+	}
+}
+
+func TestParseClaimsRequiresClaims(t *testing.T) {
+	s := newTokenTestService("access-secret", "refresh-secret")
+
+	tests := []struct {
+		name   string
+		modify func(*Claims)
+	}{
+		{"missing typ", func(c *Claims) { c.Type = "" }},
+		{"unknown typ", func(c *Claims) { c.Type = "id" }},
+		{"missing did", func(c *Claims) { c.DeviceID = "" }},
+		{"missing fid", func(c *Claims) { c.FamilyID = "" }},
+		{"missing sub", func(c *Claims) { c.Subject = "" }},
+		{"missing jti", func(c *Claims) { c.ID = "" }},
+		{"missing exp", func(c *Claims) { c.ExpiresAt = nil }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := s.newClaims(TokenTypeAccess, testFamily, time.Minute)
+			tt.modify(&claims)
+
+			token := sign(t, jwt.SigningMethodHS256, claims, []byte("access-secret"))
+			if _, err := s.parseClaims(token, TokenTypeAccess); err == nil {
+				t.Error("token accepted")
+			}
+		})
+	}
+}
+
+func TestParseClaimsLeeway(t *testing.T) {
+	s := newTokenTestService("access-secret", "refresh-secret")
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		issuedAt  time.Time
+		valid     bool
+	}{
+		{"expired within leeway", now.Add(-testLeeway + 5*time.Second), now.Add(-time.Minute), true},
+		{"expired past leeway", now.Add(-testLeeway - 5*time.Second), now.Add(-time.Minute), false},
+		{"issued ahead within leeway", now.Add(time.Minute), now.Add(testLeeway - 5*time.Second), true},
+		{"issued ahead past leeway", now.Add(time.Minute), now.Add(testLeeway + 5*time.Second), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := s.newClaims(TokenTypeAccess, testFamily, time.Minute)
+			claims.ExpiresAt = jwt.NewNumericDate(tt.expiresAt)
+			claims.IssuedAt = jwt.NewNumericDate(tt.issuedAt)
+
+			token := sign(t, jwt.SigningMethodHS256, claims, []byte("access-secret"))
+			_, err := s.parseClaims(token, TokenTypeAccess)
+			if tt.valid && err != nil {
+				t.Errorf("token rejected: %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("token accepted")
+			}
+		})
+	}
+}
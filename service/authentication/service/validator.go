@@ -1,34 +1,241 @@
 package service
 
 import (
+	"errors"
 	validation "github.com/go-ozzo/ozzo-validation/v4"
-	"regexp"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+	"github.com/hosseinasadian/chat-application/pkg/phone"
+	"net/url"
 )
 
 type Validator struct {
-	otpLength  int
-	phoneRegex string
+	otpLength int
+	phones    phone.Parser
 }
 
-func newValidator(otpLength int, phoneRegex string) Validator {
-	return Validator{otpLength: otpLength, phoneRegex: phoneRegex}
+func newValidator(otpLength int, phones phone.Parser) Validator {
+	return Validator{otpLength: otpLength, phones: phones}
 }
 
 func (v Validator) validateSendOtp(req SendOtpRequest) error {
 	return validation.ValidateStruct(&req,
-		validation.Field(&req.Phone, validation.Required, validation.Match(regexp.MustCompile(v.phoneRegex))),
+		validation.Field(&req.Phone, validation.Required, validation.By(v.phoneNumber)),
 	)
 }
 
 func (v Validator) validateVerifyOtp(req VerifyOtpRequest) error {
 	return validation.ValidateStruct(&req,
 		validation.Field(&req.Otp, validation.Required, validation.Length(6, 6)),
-		validation.Field(&req.Phone, validation.Required, validation.Match(regexp.MustCompile(v.phoneRegex))),
+		validation.Field(&req.Phone, validation.Required, validation.By(v.phoneNumber)),
 	)
 }
 
+func (v Validator) phoneNumber(value interface{}) error {
+	raw, _ := value.(string)
+	_, err := v.phones.Normalize(raw)
+	return err
+}
+
 func (v Validator) validateRefreshToken(req RefreshRequest) error {
 	return validation.ValidateStruct(&req,
 		validation.Field(&req.RefreshToken, validation.Required),
 	)
 }
+
+func (v Validator) validateCreateBot(req CreateBotRequest) error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.Name, validation.Required, validation.Length(1, 64)),
+	)
+}
+
+func (v Validator) validateCreateAPIKey(req CreateAPIKeyRequest) error {
+	scopes := make([]interface{}, len(APIKeyScopes))
+	for i, scope := range APIKeyScopes {
+		scopes[i] = scope
+	}
+
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.Name, validation.Length(0, 64)),
+		validation.Field(&req.Scopes, validation.Required, validation.Each(validation.In(scopes...))),
+	)
+}
+
+func (v Validator) validateIntrospect(req IntrospectRequest) error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.Token, validation.Required),
+		validation.Field(&req.TokenTypeHint, validation.In(TokenTypeHintAccess, TokenTypeHintRefresh, TokenTypeHintAPIKey)),
+	)
+}
+
+func (v Validator) validateTOTPCode(code string) error {
+	return validation.Validate(code, validation.Required, validation.Length(6, 6))
+}
+
+func (v Validator) validateVerifyMFA(req VerifyMFARequest) error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.MFAToken, validation.Required),
+		validation.Field(&req.Code, validation.Required, validation.Length(6, 9)),
+	)
+}
+
+func (v Validator) validateFinishPasskeyRegistration(req FinishPasskeyRegistrationRequest) error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.Name, validation.Length(0, 64)),
+		validation.Field(&req.Credential, validation.Required),
+	)
+}
+
+func (v Validator) validateFinishPasskeyLogin(req FinishPasskeyLoginRequest) error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.SessionID, validation.Required),
+		validation.Field(&req.Credential, validation.Required),
+	)
+}
+
+func (v Validator) validateCreateOAuthClient(req CreateOAuthClientRequest) error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.Name, validation.Required, validation.Length(1, 64)),
+		validation.Field(&req.RedirectURIs, validation.Required, validation.Length(1, 10), validation.Each(validation.By(redirectURI))),
+	)
+}
+
+func (v Validator) validateAuthorize(req AuthorizeRequest) error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.State, validation.Length(0, 512)),
+		validation.Field(&req.Nonce, validation.Length(0, 512)),
+		validation.Field(&req.CodeChallenge, validation.Required, validation.Length(43, 128)),
+		validation.Field(&req.CodeChallengeMethod, validation.Required, validation.In(codeChallengeMethodS256)),
+	)
+}
+
+// redirectURI accepts absolute urls without a fragment, as RFC 6749 requires
+// for registered redirection endpoints.
+func redirectURI(value interface{}) error {
+	raw, _ := value.(string)
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Host == "" || u.Fragment != "" {
+		return errors.New("must be an absolute URL without a fragment")
+	}
+
+	return nil
+}
+
+func (v Validator) validateLinkEmail(req LinkEmailRequest) error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.Email, validation.Required, validation.Length(3, 254), is.EmailFormat),
+	)
+}
+
+func (v Validator) validateVerifyEmail(req VerifyEmailRequest) error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.Code, validation.Required, validation.Length(6, 6)),
+	)
+}
+
+func (v Validator) validateSendEmailLogin(req SendEmailLoginRequest) error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.Email, validation.Required, validation.Length(3, 254), is.EmailFormat),
+	)
+}
+
+func (v Validator) validateVerifyEmailOtp(req VerifyEmailOtpRequest) error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.Email, validation.Required, is.EmailFormat),
+		validation.Field(&req.Otp, validation.Required, validation.Length(6, 6)),
+	)
+}
+
+func (v Validator) validateVerifyMagicLink(req VerifyMagicLinkRequest) error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.Token, validation.Required),
+	)
+}
+
+func (v Validator) validateStartPhoneChange(req StartPhoneChangeRequest) error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.NewPhone, validation.Required, validation.By(v.phoneNumber)),
+	)
+}
+
+func (v Validator) validateConfirmPhoneChange(req ConfirmPhoneChangeRequest) error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.NewCode, validation.Required, validation.Length(6, 6)),
+		validation.Field(&req.OldCode, validation.Length(6, 6)),
+		validation.Field(&req.TOTPCode, validation.Length(6, 9)),
+	)
+}
+
+func (v Validator) validateListSecurityEvents(req ListSecurityEventsRequest, max int64) error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.Limit, validation.Min(0), validation.Max(int(max))),
+	)
+}
+
+func (v Validator) validateQueryAuditLog(req QueryAuditLogRequest) error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.Limit, validation.Min(0), validation.Max(1000)),
+	)
+}
+
+func (v Validator) validateUpdateDevice(req UpdateDeviceRequest) error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.Name, validation.NilOrNotEmpty, validation.Length(1, 64)),
+	)
+}
+
+func (v Validator) validateCompleteDeviceApproval(req CompleteDeviceApprovalRequest) error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.ApprovalToken, validation.Required),
+	)
+}
+
+func (v Validator) validateReportUser(req ReportUserRequest) error {
+	reasons := make([]interface{}, len(ReportReasons))
+	for i, reason := range ReportReasons {
+		reasons[i] = reason
+	}
+
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.UserID, validation.Required, is.UUID),
+		validation.Field(&req.Reason, validation.Required, validation.In(reasons...)),
+		validation.Field(&req.Details, validation.Length(0, 1000)),
+	)
+}
+
+func (v Validator) validateAdminLookupUser(req AdminLookupUserRequest) error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.UserID, validation.Required.When(req.Phone == "").Error("user_id or phone is required")),
+		validation.Field(&req.Phone, validation.When(req.Phone != "", validation.By(v.phoneNumber))),
+	)
+}
+
+func (v Validator) validateAdminUnlockLogin(req AdminUnlockLoginRequest) error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.Phone, validation.Required, validation.By(v.phoneNumber)),
+	)
+}
+
+func (v Validator) validateAdminBanPhone(req AdminBanPhoneRequest) error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.Phone, validation.Required, validation.By(v.phoneNumber)),
+		validation.Field(&req.Reason, validation.Length(0, 200)),
+	)
+}
+
+func (v Validator) validateAdminListReports(req AdminListReportsRequest) error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.Limit, validation.Min(0), validation.Max(1000)),
+	)
+}
+
+func (v Validator) validateAdminSetRole(req AdminSetRoleRequest) error {
+	roles := make([]interface{}, len(AdminRoles))
+	for i, role := range AdminRoles {
+		roles[i] = role
+	}
+
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.UserID, validation.Required),
+		validation.Field(&req.Role, validation.In(roles...)),
+	)
+}